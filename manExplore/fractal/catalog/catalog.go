@@ -0,0 +1,408 @@
+// Package catalog indexes saved images by their absolute path in a bbolt
+// file next to the configuration. Each entry holds the image's
+// MandelData, a thumbnail and the tags and notes given to it. Programs
+// open the catalog for each change and close it again, so manExplore and
+// the command line tools can share it.
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+
+	"jsdey.com/fractal"
+)
+
+// ThumbSize is the width of the thumbnails kept in the catalog.
+const ThumbSize = 192
+
+var catalogBucket = []byte("images")
+
+// Entry is one image in the catalog.
+type Entry struct {
+	Path    string // Absolute
+	Meta    fractal.MandelData
+	Tags    []string `json:",omitempty"`
+	Notes   string   `json:",omitempty"`
+	Added   time.Time
+	ModTime time.Time // Of the image when it was indexed
+	Thumb   []byte    // png
+}
+
+// Depth is the zoom depth of the entry, see fractal.Depth.
+func (e *Entry) Depth() float64 {
+	return fractal.Depth(e.Meta.Scale)
+}
+
+// Thumbnail decodes the entry's thumbnail.
+func (e *Entry) Thumbnail() (image.Image, error) {
+	return png.Decode(bytes.NewReader(e.Thumb))
+}
+
+// Catalog is an open catalog file.
+type Catalog struct {
+	db *bolt.DB
+}
+
+// File is where Open keeps the catalog when given no name.
+func File() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mandel", "catalog.db"), nil
+}
+
+// Open opens or creates a catalog, File if fileName is empty. It waits a
+// few seconds for another program to close it.
+func Open(fileName string) (*Catalog, error) {
+	if fileName == "" {
+		var err error
+		fileName, err = File()
+		if err != nil {
+			return nil, fmt.Errorf("Open: %w", err)
+		}
+	}
+	err := os.MkdirAll(filepath.Dir(fileName), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("Open: %w", err)
+	}
+	db, err := bolt.Open(fileName, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("Open: %s: %w", fileName, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(catalogBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Open: %s: %w", fileName, err)
+	}
+	return &Catalog{db: db}, nil
+}
+
+// Close closes the catalog file.
+func (c *Catalog) Close() error {
+	return c.db.Close()
+}
+
+// Add indexes an image that carries MandelData, or indexes it again after
+// it changed. The tags and notes of an image already in the catalog are
+// kept.
+func (c *Catalog) Add(fileName string) (*Entry, error) {
+	path, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	md, err := fractal.ReadMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+	thumb, err := thumbnail(path)
+	if err != nil {
+		return nil, fmt.Errorf("Add: %s: %w", fileName, err)
+	}
+
+	e := &Entry{Path: path, Meta: *md, Added: time.Now(), ModTime: info.ModTime(), Thumb: thumb}
+	err = c.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(catalogBucket)
+		if old, err := getEntry(b, path); err == nil {
+			e.Tags, e.Notes, e.Added = old.Tags, old.Notes, old.Added
+		}
+		return putEntry(b, e)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Add: %w", err)
+	}
+	return e, nil
+}
+
+// Scan adds every image in dir with MandelData that is new or changed
+// since it was indexed, and drops entries of images in dir that are gone.
+// Files without metadata are skipped.
+func (c *Catalog) Scan(dir string) (added, removed int, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	names, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, de := range names {
+		ext := strings.ToLower(filepath.Ext(de.Name()))
+		if de.IsDir() || !slices.Contains(fractal.ImageExtensions, ext) {
+			continue
+		}
+		path := filepath.Join(dir, de.Name())
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		if e, err := c.Get(path); err == nil && e.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		if _, err := c.Add(path); err == nil {
+			added++
+		}
+	}
+
+	entries, err := c.All()
+	if err != nil {
+		return added, 0, err
+	}
+	for _, e := range entries {
+		if filepath.Dir(e.Path) != dir {
+			continue
+		}
+		if _, err := os.Stat(e.Path); errors.Is(err, os.ErrNotExist) {
+			err = c.Remove(e.Path)
+			if err != nil {
+				return added, removed, err
+			}
+			removed++
+		}
+	}
+	return added, removed, nil
+}
+
+// Get returns the entry of an image.
+func (c *Catalog) Get(fileName string) (*Entry, error) {
+	path, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var e *Entry
+	err = c.db.View(func(tx *bolt.Tx) error {
+		e, err = getEntry(tx.Bucket(catalogBucket), path)
+		return err
+	})
+	return e, err
+}
+
+// Remove drops an image from the catalog, the image itself is left alone.
+func (c *Catalog) Remove(fileName string) error {
+	return c.update(fileName, nil)
+}
+
+// SetTags replaces the tags of an image. Tags are trimmed, lower cased
+// and kept sorted without duplicates.
+func (c *Catalog) SetTags(fileName string, tags []string) error {
+	return c.update(fileName, func(e *Entry) {
+		e.Tags = nil
+		for _, t := range tags {
+			t = strings.ToLower(strings.TrimSpace(t))
+			if t != "" {
+				e.Tags = append(e.Tags, t)
+			}
+		}
+		slices.Sort(e.Tags)
+		e.Tags = slices.Compact(e.Tags)
+	})
+}
+
+// SetNotes replaces the notes of an image.
+func (c *Catalog) SetNotes(fileName, notes string) error {
+	return c.update(fileName, func(e *Entry) {
+		e.Notes = notes
+	})
+}
+
+// update changes the entry of an image with fn, or deletes it if fn is
+// nil.
+func (c *Catalog) update(fileName string, fn func(e *Entry)) error {
+	path, err := filepath.Abs(fileName)
+	if err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(catalogBucket)
+		e, err := getEntry(b, path)
+		if err != nil {
+			return err
+		}
+		if fn == nil {
+			return b.Delete([]byte(e.Path))
+		}
+		fn(e)
+		return putEntry(b, e)
+	})
+}
+
+// All returns every entry, newest first.
+func (c *Catalog) All() ([]*Entry, error) {
+	return c.Find(Query{})
+}
+
+// Query selects catalog entries. Zero fields match everything.
+type Query struct {
+	Tags               []string // Entries with all of these tags
+	HasDepth           bool     // Whether the depth range applies
+	MinDepth, MaxDepth float64  // Range of Depth
+	Text               string   // Found in the path or notes, in any case
+}
+
+// Find returns the entries that match q, newest first.
+func (c *Catalog) Find(q Query) ([]*Entry, error) {
+	var found []*Entry
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(catalogBucket).ForEach(func(k, v []byte) error {
+			e := &Entry{}
+			err := json.Unmarshal(v, e)
+			if err != nil {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+			if q.match(e) {
+				found = append(found, e)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Find: %w", err)
+	}
+	slices.SortFunc(found, func(a, b *Entry) int {
+		return b.Added.Compare(a.Added)
+	})
+	return found, nil
+}
+
+// ParseQuery reads a query from words: min..max is a depth range,
+// either side may be left out to leave it open, text:word looks in the
+// paths and notes, and any other word is a tag. Words are separated by
+// spaces or commas.
+func ParseQuery(s string) (Query, error) {
+	var q Query
+	for _, word := range strings.FieldsFunc(s, isTagSeparator) {
+		if text, ok := strings.CutPrefix(word, "text:"); ok {
+			q.Text = strings.TrimSpace(q.Text + " " + text)
+			continue
+		}
+		lo, hi, ok := strings.Cut(word, "..")
+		if !ok {
+			q.Tags = append(q.Tags, word)
+			continue
+		}
+		var err error
+		q.HasDepth = true
+		q.MinDepth, q.MaxDepth = math.Inf(-1), math.Inf(1)
+		if lo != "" {
+			q.MinDepth, err = strconv.ParseFloat(lo, 64)
+		}
+		if err == nil && hi != "" {
+			q.MaxDepth, err = strconv.ParseFloat(hi, 64)
+		}
+		if err != nil {
+			return q, fmt.Errorf("ParseQuery: depth range %q: %w", word, err)
+		}
+	}
+	return q, nil
+}
+
+// SplitTags splits the tags typed as words separated by spaces or commas.
+func SplitTags(s string) []string {
+	return strings.FieldsFunc(s, isTagSeparator)
+}
+
+func isTagSeparator(r rune) bool {
+	return r == ',' || unicode.IsSpace(r)
+}
+
+func (q Query) match(e *Entry) bool {
+	for _, t := range q.Tags {
+		if !slices.Contains(e.Tags, strings.ToLower(t)) {
+			return false
+		}
+	}
+	if d := e.Depth(); q.HasDepth && (d < q.MinDepth || d > q.MaxDepth) {
+		return false
+	}
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		if !strings.Contains(strings.ToLower(e.Path), text) &&
+			!strings.Contains(strings.ToLower(e.Notes), text) {
+			return false
+		}
+	}
+	return true
+}
+
+// AddImage adds a newly saved image to the default catalog.
+func AddImage(fileName string) error {
+	c, err := Open("")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	_, err = c.Add(fileName)
+	return err
+}
+
+func getEntry(b *bolt.Bucket, path string) (*Entry, error) {
+	v := b.Get([]byte(path))
+	if v == nil {
+		return nil, fmt.Errorf("%s is not in the catalog", path)
+	}
+	e := &Entry{}
+	err := json.Unmarshal(v, e)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return e, nil
+}
+
+func putEntry(b *bolt.Bucket, e *Entry) error {
+	v, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(e.Path), v)
+}
+
+// thumbnail scales the image down to ThumbSize wide and returns it as
+// png.
+func thumbnail(fileName string) ([]byte, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	r := img.Bounds()
+	w := min(ThumbSize, r.Dx())
+	h := max(1, r.Dy()*w/max(r.Dx(), 1))
+	thumb := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, r, draw.Src, nil)
+
+	var b bytes.Buffer
+	err = png.Encode(&b, thumb)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
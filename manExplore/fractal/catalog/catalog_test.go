@@ -0,0 +1,119 @@
+package catalog
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"jsdey.com/fractal"
+)
+
+func TestParseQuery(t *testing.T) {
+	inf := math.Inf(1)
+	tests := []struct {
+		s    string
+		want Query
+		err  string // Part of the error, empty for none
+	}{
+		{"", Query{}, ""},
+		{"spiral", Query{Tags: []string{"spiral"}}, ""},
+		{"spiral, seahorse  minibrot", Query{Tags: []string{"spiral", "seahorse", "minibrot"}}, ""},
+		{"4..8", Query{HasDepth: true, MinDepth: 4, MaxDepth: 8}, ""},
+		{"0..0", Query{HasDepth: true}, ""},
+		{"..8", Query{HasDepth: true, MinDepth: -inf, MaxDepth: 8}, ""},
+		{"4..", Query{HasDepth: true, MinDepth: 4, MaxDepth: inf}, ""},
+		{"text:valley", Query{Text: "valley"}, ""},
+		{"text:deep text:valley", Query{Text: "deep valley"}, ""},
+		{"spiral 2.5..6 text:blue", Query{Tags: []string{"spiral"}, HasDepth: true, MinDepth: 2.5, MaxDepth: 6, Text: "blue"}, ""},
+		{"deep..er", Query{}, `depth range "deep..er"`},
+		{"4..x", Query{}, `depth range "4..x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			q, err := ParseQuery(tt.s)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one with %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(q, tt.want) {
+				t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.s, q, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	e := &Entry{
+		Path:  "/home/someone/pic/Seahorse-Valley.jpg",
+		Meta:  fractal.MandelData{Scale: 1e-6}, // Depth 6
+		Tags:  []string{"seahorse", "spiral"},
+		Notes: "Deep in the valley, BLUE palette",
+	}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"spiral", true},
+		{"SPIRAL", true},
+		{"spiral seahorse", true},
+		{"spiral minibrot", false},
+		{"5..7", true},
+		{"6..6", true},
+		{"7..", false},
+		{"..5", false},
+		{"..6.5", true},
+		{"0..0", false},
+		{"text:valley", true},
+		{"text:blue", true},
+		{"text:seahorse-valley", true},
+		{"text:red", false},
+		{"seahorse 5.. text:blue", true},
+		{"seahorse 7.. text:blue", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := q.match(e); got != tt.want {
+				t.Errorf("%q matches %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryMatchScaleOne(t *testing.T) {
+	e := &Entry{Meta: fractal.MandelData{Scale: 1}} // Depth 0
+	for _, s := range []string{"", "0..0", "..0", "0.."} {
+		q, err := ParseQuery(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !q.match(e) {
+			t.Errorf("%q does not match an image at scale 1", s)
+		}
+	}
+	q, err := ParseQuery("0..0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.match(&Entry{Meta: fractal.MandelData{Scale: 1e-3}}) {
+		t.Error("0..0 matches an image at depth 3")
+	}
+}
+
+func TestSplitTags(t *testing.T) {
+	got := SplitTags(" spiral,seahorse\tdeep ,, ")
+	want := []string{"spiral", "seahorse", "deep"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitTags = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package fractal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+)
+
+// Config holds the settings shared by manExplore and the command line
+// tools. It is read from mandel/config.json in the user's config
+// directory, ~/.config/mandel/config.json on Linux.
+type Config struct {
+	Artist    string // EXIF Artist and XMP dc:creator of saved images
+	Copyright string // EXIF Copyright and XMP dc:rights of saved images
+	Sidecar   bool   // Also write a .xmp file next to every saved image
+
+	OutputDir    string // Folder of manExplore's pictures, DefaultOutputDir if empty
+	NameTemplate string // Names of saved pictures, see ExpandName
+
+	Explorer Explorer // manExplore's preferences
+}
+
+// Explorer holds the preferences of manExplore, edited in its settings
+// dialog. Zero values mean the defaults.
+type Explorer struct {
+	X, Y, Scale   float64           // Start view, -0.75, 0, 1 if Scale is 0
+	Palette       string            // Empty for the theme colours
+	ZoomStep      float64           // Factor of one zoom key, 1.1
+	PanStep       float64           // Share of the scale an arrow moves, 0.2
+	RotateStep    float64           // Degrees, 5
+	Width, Height int               // Window size, 480 x 270
+	Keys          map[string]string // Action to key, see DefaultKeys
+}
+
+// WithDefaults fills in the zero values.
+func (e Explorer) WithDefaults() Explorer {
+	if e.Scale <= 0 {
+		e.X, e.Y, e.Scale = -0.75, 0, 1
+	}
+	if e.ZoomStep <= 1 {
+		e.ZoomStep = 1.1
+	}
+	if e.PanStep <= 0 {
+		e.PanStep = 0.2
+	}
+	if e.RotateStep <= 0 {
+		e.RotateStep = 5
+	}
+	if e.Width <= 0 || e.Height <= 0 {
+		e.Width, e.Height = 480, 270
+	}
+	keys := maps.Clone(DefaultKeys)
+	for action, key := range e.Keys {
+		if _, ok := keys[action]; ok && key != "" {
+			keys[action] = key
+		}
+	}
+	e.Keys = keys
+	return e
+}
+
+// ConfigFile is where LoadConfig looks for the configuration.
+func ConfigFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mandel", "config.json"), nil
+}
+
+// LoadConfig reads the configuration. A missing file gives the defaults.
+func LoadConfig() (*Config, error) {
+	cfg := &Config{}
+	fileName, err := ConfigFile()
+	if err != nil {
+		return cfg, nil
+	}
+	b, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("LoadConfig: %s: %w", fileName, err)
+	}
+	return cfg, nil
+}
+
+// SaveConfig writes the configuration where LoadConfig reads it.
+func SaveConfig(cfg *Config) error {
+	fileName, err := ConfigFile()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(fileName), 0755)
+	if err != nil {
+		return fmt.Errorf("SaveConfig: %w", err)
+	}
+	err = writeFile(fileName, append(b, '\n'), true)
+	if err != nil {
+		return fmt.Errorf("SaveConfig: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package fractal
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// View describes a region of the complex plane and how it is coloured.
+// It carries no fyne state, so the command line tools and the tile server
+// can render with the same math that the explorer uses on screen.
+type View struct {
+	X, Y       float64 // Centre of the view, Y is negated as in Fractal
+	Scale      float64 // 1.0 shows the whole set
+	Rotation   float64 // Degrees, turning the view about its centre
+	Iterations int
+	Formula    string
+	Palette    string
+	Offset     float64 // Shifts the palette, 1.0 is a full cycle
+	Samples    int     // Supersampling, each pixel averages Samples² points
+}
+
+// AutoIterations is the iteration count the explorer uses for a scale.
+func AutoIterations(scale float64) int {
+	if scale >= 1.0 {
+		return 100
+	}
+	return int(100 * (1 + math.Pow((math.Log10(1/scale)), 1.25)))
+}
+
+// Point transforms pixel space to mandelbrot space for an image that is
+// w by h pixels, turning it by Rotation about the centre.
+func (v View) Point(px, py float64, w, h int) complex128 {
+	drawScale := 3.5 * v.Scale
+	aspect := float64(h) / float64(w)
+	dx := ((px / float64(w)) - 0.5) * drawScale
+	dy := ((py / float64(w)) - (0.5 * aspect)) * drawScale
+	if v.Rotation != 0 {
+		sin, cos := math.Sincos(v.Rotation * math.Pi / 180)
+		dx, dy = dx*cos-dy*sin, dx*sin+dy*cos
+	}
+	return complex(dx+v.X, dy-v.Y)
+}
+
+// Pixel is the inverse of Point, it finds where c lies in a w by h image
+// of the view.
+func (v View) Pixel(c complex128, w, h int) (px, py float64) {
+	drawScale := 3.5 * v.Scale
+	aspect := float64(h) / float64(w)
+	dx, dy := real(c)-v.X, imag(c)+v.Y
+	if v.Rotation != 0 {
+		sin, cos := math.Sincos(v.Rotation * math.Pi / 180)
+		dx, dy = dx*cos+dy*sin, -dx*sin+dy*cos
+	}
+	px = (dx/drawScale + 0.5) * float64(w)
+	py = (dy/drawScale + 0.5*aspect) * float64(w)
+	return px, py
+}
+
+// Render computes a w by h image of the view. Rows are spread over all
+// available CPUs. A zero Iterations uses AutoIterations.
+func (v View) Render(w, h int) (*image.RGBA, error) {
+	if v.Iterations <= 0 {
+		v.Iterations = AutoIterations(v.Scale)
+	}
+	formula, err := LookupFormula(v.Formula)
+	if err != nil {
+		return nil, err
+	}
+	palette, err := LookupPalette(v.Palette)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	eachRow(h, func(py int) {
+		for px := 0; px < w; px++ {
+			img.SetRGBA(px, py, v.pixel(formula, palette, px, py, w, h))
+		}
+	})
+	return img, nil
+}
+
+// eachRow calls fn for rows 0 to h-1, spread over all available CPUs.
+func eachRow(h int, fn func(py int)) {
+	rows := make(chan int, h)
+	for py := 0; py < h; py++ {
+		rows <- py
+	}
+	close(rows)
+
+	var wg sync.WaitGroup
+	for n := 0; n < runtime.NumCPU(); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for py := range rows {
+				fn(py)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Shader returns a function that colours single points of the plane with
+// the view's formula, palette, iterations and offset. It is for callers
+// that sample the plane in their own pattern instead of a pixel grid.
+func (v View) Shader() (func(c complex128) color.RGBA, error) {
+	if v.Iterations <= 0 {
+		v.Iterations = AutoIterations(v.Scale)
+	}
+	formula, err := LookupFormula(v.Formula)
+	if err != nil {
+		return nil, err
+	}
+	palette, err := LookupPalette(v.Palette)
+	if err != nil {
+		return nil, err
+	}
+	return func(c complex128) color.RGBA {
+		return v.color(formula, palette, c)
+	}, nil
+}
+
+// Field holds the smoothed escape values of a rendered view, so that it
+// can be coloured again with another palette or offset without iterating.
+type Field struct {
+	W, H    int
+	Samples int       // Values per pixel along each axis
+	Mu      []float64 // Samples² values per pixel, row by row, -1 inside the set
+}
+
+// Compute iterates a w by h image of the view and keeps the escape values
+// instead of colours. The view's palette and offset are not used.
+func (v View) Compute(w, h int) (*Field, error) {
+	if v.Iterations <= 0 {
+		v.Iterations = AutoIterations(v.Scale)
+	}
+	formula, err := LookupFormula(v.Formula)
+	if err != nil {
+		return nil, err
+	}
+	if v.Samples < 1 {
+		v.Samples = 1
+	}
+
+	n := v.Samples * v.Samples
+	f := &Field{W: w, H: h, Samples: v.Samples, Mu: make([]float64, w*h*n)}
+	eachRow(h, func(py int) {
+		i := py * w * n
+		for px := 0; px < w; px++ {
+			for sy := 0; sy < v.Samples; sy++ {
+				for sx := 0; sx < v.Samples; sx++ {
+					x, y := v.sample(px, py, sx, sy)
+					f.Mu[i] = v.value(formula, v.Point(x, y, w, h))
+					i++
+				}
+			}
+		}
+	})
+	return f, nil
+}
+
+// Colorize colours the field as Render would have with the palette and
+// offset.
+func (f *Field) Colorize(palette string, offset float64) (*image.RGBA, error) {
+	p, err := LookupPalette(palette)
+	if err != nil {
+		return nil, err
+	}
+
+	n := f.Samples * f.Samples
+	img := image.NewRGBA(image.Rect(0, 0, f.W, f.H))
+	eachRow(f.H, func(py int) {
+		i := py * f.W * n
+		for px := 0; px < f.W; px++ {
+			var r, g, b int
+			for s := 0; s < n; s++ {
+				c := shade(p, f.Mu[i], offset)
+				r += int(c.R)
+				g += int(c.G)
+				b += int(c.B)
+				i++
+			}
+			img.SetRGBA(px, py, color.RGBA{uint8(r / n), uint8(g / n), uint8(b / n), 0xff})
+		}
+	})
+	return img, nil
+}
+
+// pixel colours one pixel, averaging over a Samples by Samples grid.
+func (v View) pixel(formula Formula, palette *Palette, px, py, w, h int) color.RGBA {
+	if v.Samples <= 1 {
+		return v.color(formula, palette, v.Point(float64(px), float64(py), w, h))
+	}
+
+	var r, g, b int
+	for sy := 0; sy < v.Samples; sy++ {
+		for sx := 0; sx < v.Samples; sx++ {
+			x, y := v.sample(px, py, sx, sy)
+			c := v.color(formula, palette, v.Point(x, y, w, h))
+			r += int(c.R)
+			g += int(c.G)
+			b += int(c.B)
+		}
+	}
+	n := v.Samples * v.Samples
+	return color.RGBA{uint8(r / n), uint8(g / n), uint8(b / n), 0xff}
+}
+
+// sample is the position of sub-sample sx, sy of a pixel when
+// supersampling.
+func (v View) sample(px, py, sx, sy int) (float64, float64) {
+	x := float64(px) + (float64(sx)+0.5)/float64(v.Samples) - 0.5
+	y := float64(py) + (float64(sy)+0.5)/float64(v.Samples) - 0.5
+	return x, y
+}
+
+// color iterates a single point and maps the result through the palette.
+func (v View) color(formula Formula, palette *Palette, c complex128) color.RGBA {
+	return shade(palette, v.value(formula, c), v.Offset)
+}
+
+// value iterates a single point and returns its smoothed escape value, or
+// -1 for points inside the set.
+func (v View) value(formula Formula, c complex128) float64 {
+	n, z := escape(formula, c, v.Iterations)
+	if n == v.Iterations {
+		return -1
+	}
+	return smooth(n, z, v.Iterations)
+}
+
+// shade maps an escape value through the palette, shifted by offset.
+func shade(palette *Palette, mu, offset float64) color.RGBA {
+	if mu < 0 {
+		return palette.Interior
+	}
+	if offset != 0 {
+		mu = math.Mod(mu+offset, 1)
+		if mu < 0 {
+			mu++
+		}
+	}
+	return palette.Color(mu)
+}
+
+// escape iterates z = formula(z, c) until z leaves the radius 2 circle or
+// the iteration limit is reached.
+func escape(formula Formula, c complex128, iterations int) (int, complex128) {
+	z := complex(0, 0)
+
+	var n int
+	for n = 0; n < iterations; n++ {
+		if real(z)*real(z)+imag(z)*imag(z) > 4 {
+			break
+		}
+		z = formula(z, c)
+	}
+	return n, z
+}
+
+// smooth returns the normalised, continuous escape count in [0, 1].
+func smooth(n int, z complex128, iterations int) float64 {
+	abs := math.Hypot(real(z), imag(z))
+	adj := float64(n) + 1. - math.Log(math.Log2(abs))
+	mu := adj / float64(iterations)
+	return math.Max(0, math.Min(1, mu))
+}
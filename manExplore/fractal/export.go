@@ -0,0 +1,317 @@
+package fractal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strconv"
+	"sync/atomic"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// How an export whose shape differs from the window frames the view.
+// AspectWidth keeps the width of the window, as the engine does, so a
+// taller image shows more above and below. AspectFit shows everything in
+// the window and AspectFill shows nothing outside it.
+const (
+	AspectWidth = "Keep width"
+	AspectFit   = "Fit window"
+	AspectFill  = "Fill window"
+)
+
+// ExportOptions are the choices of the export dialog.
+type ExportOptions struct {
+	Width, Height int
+	Aspect        string // AspectWidth, AspectFit or AspectFill
+	Format        string // jpeg, png or tiff
+	Quality       int    // jpeg quality
+	Samples       int    // Anti-aliasing, each pixel averages Samples² points
+	Palette       string // Empty for the theme colours
+}
+
+// maxExportSize limits each side of an export.
+const maxExportSize = 20000
+
+const (
+	sizeWindow  = "Window"
+	sizeCustom  = "Custom"
+	themeColors = "Theme colours"
+)
+
+var exportSizes = []struct {
+	name string
+	w, h int
+}{
+	{"1280 x 720 (HD)", 1280, 720},
+	{"1920 x 1080 (Full HD)", 1920, 1080},
+	{"2560 x 1440 (QHD)", 2560, 1440},
+	{"3840 x 2160 (4K)", PX, PY},
+	{"7680 x 4320 (8K)", 7680, 4320},
+	{"2160 x 3840 (portrait)", 2160, 3840},
+	{"4096 x 4096 (square)", 4096, 4096},
+}
+
+// export is one export, with the view taken when it was started.
+type export struct {
+	ExportOptions
+	view       View
+	iterations uint
+	shade      func(c complex128) color.RGBA // nil for the theme colours
+	theme      func(c complex128, iterations uint) color.Color
+	md         *MandelData
+}
+
+// newExport takes the current view for an export. It runs on the UI
+// thread, the export itself does not touch f.
+func (f *Fractal) newExport(opt ExportOptions, cfg *Config) (*export, error) {
+	v := f.view()
+	v.Samples = max(opt.Samples, 1)
+	v.Palette = opt.Palette
+	if v.Iterations <= 0 {
+		v.Iterations = AutoIterations(v.Scale)
+	}
+
+	size := f.canvas.Size()
+	if size.Width > 0 && size.Height > 0 {
+		window := float64(size.Height / size.Width)
+		image := float64(opt.Height) / float64(opt.Width)
+		if (opt.Aspect == AspectFit && image < window) || (opt.Aspect == AspectFill && image > window) {
+			v.Scale *= window / image
+		}
+	}
+
+	e := &export{ExportOptions: opt, view: v, iterations: uint(v.Iterations), theme: f.themeColor}
+	if opt.Palette == "" {
+		if v.Formula != "" && v.Formula != DefaultFormula {
+			return nil, fmt.Errorf("the theme colours only draw the %s formula, pick a palette", DefaultFormula)
+		}
+		e.md = themeMandelData(v, opt.Width, opt.Height)
+	} else {
+		shade, err := v.Shader()
+		if err != nil {
+			return nil, err
+		}
+		e.shade = shade
+		e.md = NewMandelData(v, opt.Width, opt.Height)
+	}
+	e.md.Author = cfg.Artist
+	return e, nil
+}
+
+// render draws the image, reporting the share of rows done to progress.
+// It stops early with ctx's error when ctx is cancelled.
+func (e *export) render(ctx context.Context, progress func(done float64)) (*image.RGBA, error) {
+	w, h, n := e.Width, e.Height, e.view.Samples
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	step := max(1, h/200)
+	var rows atomic.Int64
+
+	eachRow(h, func(py int) {
+		if ctx.Err() != nil {
+			return
+		}
+		for px := 0; px < w; px++ {
+			var r, g, b uint32
+			for sy := 0; sy < n; sy++ {
+				for sx := 0; sx < n; sx++ {
+					x, y := e.view.sample(px, py, sx, sy)
+					c := e.color(e.view.Point(x, y, w, h))
+					r += uint32(c.R)
+					g += uint32(c.G)
+					b += uint32(c.B)
+				}
+			}
+			s := uint32(n * n)
+			img.SetRGBA(px, py, color.RGBA{uint8(r / s), uint8(g / s), uint8(b / s), 0xff})
+		}
+		if done := rows.Add(1); done%int64(step) == 0 {
+			progress(float64(done) / float64(h))
+		}
+	})
+	return img, ctx.Err()
+}
+
+func (e *export) color(c complex128) color.RGBA {
+	if e.shade != nil {
+		return e.shade(c)
+	}
+	return color.RGBAModel.Convert(e.theme(c, e.iterations)).(color.RGBA)
+}
+
+// showExport asks how to export the current view and starts the export.
+func (f *Fractal) showExport() {
+	if f.exporting.Load() {
+		dialog.ShowInformation("Export", "An export is still running.", f.window)
+		return
+	}
+	opt := f.export
+	if opt.Width == 0 {
+		opt = ExportOptions{Width: PX, Height: PY, Aspect: AspectWidth, Format: "jpeg",
+			Quality: jpeg.DefaultQuality, Samples: 1}
+		if f.shade != nil {
+			opt.Palette = f.palette
+			if opt.Palette == "" {
+				opt.Palette = DefaultPalette
+			}
+		}
+	}
+
+	width, height := widget.NewEntry(), widget.NewEntry()
+	width.SetText(strconv.Itoa(opt.Width))
+	height.SetText(strconv.Itoa(opt.Height))
+	sizes := []string{sizeWindow}
+	for _, s := range exportSizes {
+		sizes = append(sizes, s.name)
+	}
+	sizes = append(sizes, sizeCustom)
+	var setting bool // The entries are being filled from the preset
+	size := widget.NewSelect(sizes, func(name string) {
+		w, h := 0, 0
+		for _, s := range exportSizes {
+			if s.name == name {
+				w, h = s.w, s.h
+			}
+		}
+		if name == sizeWindow {
+			scale := f.window.Canvas().Scale()
+			w = int(f.canvas.Size().Width * scale)
+			h = int(f.canvas.Size().Height * scale)
+		}
+		if w > 0 {
+			setting = true
+			width.SetText(strconv.Itoa(w))
+			height.SetText(strconv.Itoa(h))
+			setting = false
+		}
+	})
+	size.SetSelected(sizeCustom)
+	for _, s := range exportSizes {
+		if s.w == opt.Width && s.h == opt.Height {
+			size.SetSelected(s.name)
+		}
+	}
+	custom := func(string) {
+		if !setting {
+			size.SetSelected(sizeCustom)
+		}
+	}
+	width.OnChanged, height.OnChanged = custom, custom
+
+	aspect := widget.NewSelect([]string{AspectWidth, AspectFit, AspectFill}, nil)
+	aspect.SetSelected(opt.Aspect)
+
+	quality := widget.NewSlider(1, 100)
+	quality.Step = 1
+	quality.SetValue(float64(opt.Quality))
+	qualityLabel := widget.NewLabel(strconv.Itoa(opt.Quality))
+	quality.OnChanged = func(q float64) { qualityLabel.SetText(strconv.Itoa(int(q))) }
+	qualityBox := container.NewBorder(nil, nil, nil, qualityLabel, quality)
+
+	// Only a jpeg has a quality.
+	format := widget.NewSelect([]string{"jpeg", "png", "tiff"}, func(format string) {
+		if format == "jpeg" {
+			qualityBox.Show()
+		} else {
+			qualityBox.Hide()
+		}
+	})
+	format.SetSelected(opt.Format)
+
+	samples := widget.NewSelect([]string{"off", "2 x 2", "3 x 3", "4 x 4"}, nil)
+	samples.SetSelectedIndex(min(max(opt.Samples, 1), 4) - 1)
+
+	palettes := append([]string{themeColors}, PaletteNames()...)
+	palette := widget.NewSelect(palettes, nil)
+	palette.SetSelected(themeColors)
+	if opt.Palette != "" {
+		palette.SetSelected(opt.Palette)
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Size", size),
+		widget.NewFormItem("Width", width),
+		widget.NewFormItem("Height", height),
+		widget.NewFormItem("Aspect", aspect),
+		widget.NewFormItem("Format", format),
+		widget.NewFormItem("Quality", qualityBox),
+		widget.NewFormItem("Anti-aliasing", samples),
+		widget.NewFormItem("Palette", palette),
+	}
+	d := dialog.NewForm("Export", "Export", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		w, werr := strconv.Atoi(width.Text)
+		h, herr := strconv.Atoi(height.Text)
+		if werr != nil || herr != nil || w < 1 || h < 1 || w > maxExportSize || h > maxExportSize {
+			dialog.ShowError(fmt.Errorf("the size %s x %s must be whole numbers from 1 to %d",
+				width.Text, height.Text, maxExportSize), f.window)
+			return
+		}
+		opt := ExportOptions{Width: w, Height: h, Aspect: aspect.Selected, Format: format.Selected,
+			Quality: int(quality.Value), Samples: samples.SelectedIndex() + 1}
+		if palette.Selected != themeColors {
+			opt.Palette = palette.Selected
+		}
+		f.export = opt
+		f.startExport(opt)
+	}, f.window)
+	d.Resize(fyne.NewSize(420, d.MinSize().Height))
+	d.Show()
+}
+
+// startExport renders and saves in the background, with a progress bar
+// and a button to cancel.
+func (f *Fractal) startExport(opt ExportOptions) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		dialog.ShowError(err, f.window)
+		return
+	}
+	e, err := f.newExport(opt, cfg)
+	if err != nil {
+		dialog.ShowError(err, f.window)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bar := widget.NewProgressBar()
+	progress := dialog.NewCustomWithoutButtons(
+		fmt.Sprintf("Exporting %d x %d %s", opt.Width, opt.Height, opt.Format),
+		container.NewVBox(bar, widget.NewButton("Cancel", cancel)), f.window)
+	progress.Resize(fyne.NewSize(360, progress.MinSize().Height))
+	progress.Show()
+	f.exporting.Store(true)
+
+	go func() {
+		defer cancel()
+		img, err := e.render(ctx, bar.SetValue)
+		var fileName string
+		if err == nil {
+			fileName, err = SaveImage(img, opt.Format, opt.Quality, e.md, cfg)
+		}
+		progress.Hide()
+		f.exporting.Store(false)
+
+		switch {
+		case errors.Is(err, context.Canceled):
+		case err != nil:
+			dialog.ShowError(err, f.window)
+		default:
+			if ImageSaved != nil {
+				err = ImageSaved(fileName)
+				if err != nil {
+					fmt.Println("ImageSaved:", err)
+				}
+			}
+			dialog.ShowInformation("Export", "Saved "+fileName, f.window)
+		}
+	}()
+}
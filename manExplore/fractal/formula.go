@@ -0,0 +1,53 @@
+package fractal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Formula is one step of an escape time iteration.
+type Formula func(z, c complex128) complex128
+
+// DefaultFormula is used when a View does not name one.
+const DefaultFormula = "mandelbrot"
+
+var formulas = map[string]Formula{
+	"mandelbrot": func(z, c complex128) complex128 {
+		return z*z + c
+	},
+	"burningship": func(z, c complex128) complex128 {
+		z = complex(math.Abs(real(z)), math.Abs(imag(z)))
+		return z*z + c
+	},
+	"tricorn": func(z, c complex128) complex128 {
+		z = complex(real(z), -imag(z))
+		return z*z + c
+	},
+	"multibrot3": func(z, c complex128) complex128 {
+		return z*z*z + c
+	},
+}
+
+// LookupFormula returns the formula registered under name. An empty name
+// selects DefaultFormula.
+func LookupFormula(name string) (Formula, error) {
+	if name == "" {
+		name = DefaultFormula
+	}
+	f, ok := formulas[name]
+	if !ok {
+		return nil, fmt.Errorf("LookupFormula: unknown formula %q", name)
+	}
+	return f, nil
+}
+
+// FormulaNames lists the registered formulas in alphabetical order.
+func FormulaNames() []string {
+	names := make([]string, 0, len(formulas))
+	for name := range formulas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
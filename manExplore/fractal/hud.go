@@ -0,0 +1,171 @@
+package fractal
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// hud is the overlay in the top left corner with the view's numbers.
+// Tapping it copies the view to the clipboard as flags for manSinglePNG.
+type hud struct {
+	widget.BaseWidget
+	f    *Fractal
+	text *widget.Label
+
+	mu         sync.Mutex
+	cursor     complex128
+	overCanvas bool
+	renderTime time.Duration
+	copied     bool
+}
+
+func newHUD(f *Fractal) *hud {
+	h := &hud{f: f, text: widget.NewLabel("")}
+	h.text.TextStyle.Monospace = true
+	h.ExtendBaseWidget(h)
+	return h
+}
+
+func (h *hud) CreateRenderer() fyne.WidgetRenderer {
+	bg := canvas.NewRectangle(color.NRGBA{0, 0, 0, 0x99})
+	bg.CornerRadius = theme.Padding()
+	return widget.NewSimpleRenderer(container.NewStack(bg, h.text))
+}
+
+// Tapped copies the view.
+func (h *hud) Tapped(*fyne.PointEvent) {
+	h.f.window.Clipboard().SetContent(h.f.viewFlags())
+	h.mu.Lock()
+	h.copied = true
+	h.mu.Unlock()
+	h.update()
+}
+
+// rendered records how long the last render of the view took.
+func (h *hud) rendered(d time.Duration) {
+	h.mu.Lock()
+	h.renderTime = d
+	h.mu.Unlock()
+	h.update()
+}
+
+// pointer records the point under the mouse, ok is false once it left.
+func (h *hud) pointer(c complex128, ok bool) {
+	h.mu.Lock()
+	h.cursor, h.overCanvas = c, ok
+	h.copied = false
+	h.mu.Unlock()
+	h.update()
+}
+
+// update shows the current numbers.
+func (h *hud) update() {
+	f := h.f
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var b strings.Builder
+	line := func(name, format string, a ...any) {
+		fmt.Fprintf(&b, "%-8s "+format+"\n", append([]any{name}, a...)...)
+	}
+	line("Centre", "%s, %s", formatFloat(f.currX), formatFloat(f.currY))
+	line("Scale", "%.6g  (x%.3g)", f.currScale, 1/f.currScale)
+	if f.currRotation != 0 {
+		line("Rotation", "%g°", f.currRotation)
+	}
+	line("Iter", "%d", f.currIterations)
+	if h.overCanvas {
+		line("Cursor", "%.10g, %.10g", real(h.cursor), -imag(h.cursor))
+	}
+	line("Render", "%s", h.renderTime.Round(time.Millisecond))
+	if h.copied {
+		b.WriteString("copied to the clipboard")
+	} else {
+		b.WriteString("tap to copy")
+	}
+	h.text.SetText(b.String())
+}
+
+// viewFlags is the current view as manSinglePNG flags.
+func (f *Fractal) viewFlags() string {
+	s := fmt.Sprintf("-x %s -y %s -scale %s -i %d", formatFloat(f.currX), formatFloat(f.currY),
+		formatFloat(f.currScale), f.currIterations)
+	if f.currRotation != 0 {
+		s += " -rotation " + formatFloat(f.currRotation)
+	}
+	if f.formula != "" && f.formula != DefaultFormula {
+		s += " -formula " + f.formula
+	}
+	if f.palette != "" {
+		s += " -palette " + f.palette
+	}
+	return s
+}
+
+// toggleHUD shows or hides the overlay.
+func (f *Fractal) toggleHUD() {
+	if f.hud.Visible() {
+		f.hud.Hide()
+	} else {
+		f.hud.update()
+		f.hud.Show()
+	}
+}
+
+// draw renders the view at w by h pixels on all CPUs, timing it for the
+// overlay.
+func (f *Fractal) draw(w, h int) image.Image {
+	start := time.Now()
+	// Keys change the view while the rows are drawn, so draw a copy.
+	v, shade := f.view(), f.shade
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	eachRow(h, func(py int) {
+		for px := 0; px < w; px++ {
+			img.Set(px, py, f.mandelbrot(v, shade, px, py, w, h))
+		}
+	})
+	f.hud.rendered(time.Since(start))
+	return img
+}
+
+// pointerArea follows the mouse over the fractal for the overlay.
+type pointerArea struct {
+	widget.BaseWidget
+	f *Fractal
+}
+
+func newPointerArea(f *Fractal) *pointerArea {
+	p := &pointerArea{f: f}
+	p.ExtendBaseWidget(p)
+	return p
+}
+
+func (p *pointerArea) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(canvas.NewRectangle(color.Transparent))
+}
+
+func (p *pointerArea) MouseIn(ev *desktop.MouseEvent) {
+	p.MouseMoved(ev)
+}
+
+func (p *pointerArea) MouseMoved(ev *desktop.MouseEvent) {
+	size := p.Size()
+	c := p.f.view().Point(float64(ev.Position.X), float64(ev.Position.Y),
+		int(size.Width), int(size.Height))
+	p.f.hud.pointer(c, true)
+}
+
+func (p *pointerArea) MouseOut() {
+	p.f.hud.pointer(0, false)
+}
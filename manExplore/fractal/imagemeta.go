@@ -0,0 +1,463 @@
+package fractal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"slices"
+
+	exif "github.com/dsoprea/go-exif/v3"
+	jis "github.com/dsoprea/go-jpeg-image-structure/v2"
+)
+
+// Saved images carry their MandelData as json. A jpeg keeps it in the
+// EXIF DocumentName tag, a png in an iTXt chunk with the keyword
+// MandelData. The same parameters are also embedded as XMP, and can be
+// kept in a .xmp sidecar. A tiff only has the sidecar. ReadMetadata and
+// WriteMetadata pick the place from the file's contents, not its name.
+
+const pngKeyword = "MandelData"
+
+// ImageExtensions are the files that can carry MandelData.
+var ImageExtensions = []string{".jpg", ".jpeg", ".png", ".tif", ".tiff"}
+
+var (
+	pngSignature   = []byte("\x89PNG\r\n\x1a\n")
+	jpegSignature  = []byte{0xff, 0xd8}
+	tiffSignatures = [][]byte{[]byte("II*\x00"), []byte("MM\x00*")}
+)
+
+// imageKind tells jpeg, png and tiff data apart.
+func imageKind(b []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(b, pngSignature):
+		return "png", nil
+	case bytes.HasPrefix(b, jpegSignature):
+		return "jpeg", nil
+	case bytes.HasPrefix(b, tiffSignatures[0]) || bytes.HasPrefix(b, tiffSignatures[1]):
+		return "tiff", nil
+	}
+	return "", errors.New("neither a jpeg, a png nor a tiff")
+}
+
+// ReadMetadata returns the MandelData stored in a jpeg or png. The json
+// is preferred, then embedded XMP, then a .xmp sidecar.
+func ReadMetadata(fileName string) (*MandelData, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	kind, err := imageKind(b)
+	if err != nil {
+		return nil, fmt.Errorf("ReadMetadata: %s is %w", fileName, err)
+	}
+
+	var text, xmp string
+	var ok bool
+	switch kind {
+	case "png":
+		text, ok, err = readPNGText(b, pngKeyword)
+		if err == nil && !ok {
+			xmp, _, err = readPNGText(b, xmpKeyword)
+		}
+	case "jpeg":
+		text, ok, err = readJPEGText(b)
+		if err == nil && !ok {
+			xmp, err = readJPEGXMP(b)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ReadMetadata: %s: %w", fileName, err)
+	}
+
+	var m *MandelData
+	switch {
+	case ok:
+		m, err = DecodeMandelData([]byte(text))
+	case xmp != "":
+		m, ok, err = DecodeXMP([]byte(xmp))
+	}
+	if err == nil && !ok {
+		m, ok, err = readSidecar(fileName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ReadMetadata: %s: %w", fileName, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("ReadMetadata: %s has no fractal metadata", fileName)
+	}
+	return m, nil
+}
+
+// WriteMetadata stores m in a jpeg or png, replacing what was there,
+// together with the Artist and Copyright of cfg. A nil cfg leaves those
+// out. A tiff gets a sidecar.
+func WriteMetadata(fileName string, m *MandelData, cfg *Config) error {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	kind, err := imageKind(b)
+	if err != nil {
+		return fmt.Errorf("WriteMetadata: %s is %w", fileName, err)
+	}
+	if kind == "tiff" {
+		return WriteSidecar(fileName, m, cfg)
+	}
+
+	if kind == "png" {
+		b, err = setPNGMetadata(b, m, cfg)
+	} else {
+		b, err = setJPEGMetadata(b, m, cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("WriteMetadata: %s: %w", fileName, err)
+	}
+	err = writeFile(fileName, b, true)
+	if err != nil {
+		return err
+	}
+	if cfg.Sidecar {
+		return WriteSidecar(fileName, m, cfg)
+	}
+	return nil
+}
+
+// EncodePNG writes img as a png carrying m. The sidecar of cfg is left to
+// the caller, who knows the file name.
+func EncodePNG(w io.Writer, img image.Image, m *MandelData, cfg *Config) error {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return err
+	}
+	b, err := setPNGMetadata(buf.Bytes(), m, cfg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func setPNGMetadata(b []byte, m *MandelData, cfg *Config) ([]byte, error) {
+	text, err := m.Encode()
+	if err != nil {
+		return nil, err
+	}
+	b, err = setPNGText(b, pngKeyword, string(text))
+	if err != nil {
+		return nil, err
+	}
+	return setPNGText(b, xmpKeyword, string(m.XMP(cfg)))
+}
+
+func readJPEGText(b []byte) (string, bool, error) {
+	intfc, err := jis.NewJpegMediaParser().ParseBytes(b)
+	if err != nil {
+		return "", false, err
+	}
+	sl := intfc.(*jis.SegmentList)
+
+	_, _, exifTags, err := sl.DumpExif()
+	if err != nil {
+		// A jpeg without an EXIF segment simply has no metadata.
+		if errors.Is(err, exif.ErrNoExif) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	for _, et := range exifTags {
+		if et.TagName == "DocumentName" {
+			return et.FormattedFirst, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// StripMetadata removes the fractal json and XMP from a jpeg or png and
+// deletes its .xmp sidecar, all a tiff has. Other metadata, such as the
+// EXIF Artist, stays.
+func StripMetadata(fileName string) error {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	kind, err := imageKind(b)
+	if err != nil {
+		return fmt.Errorf("StripMetadata: %s is %w", fileName, err)
+	}
+
+	if kind != "tiff" {
+		if kind == "png" {
+			b, err = removePNGText(b, pngKeyword, xmpKeyword)
+		} else {
+			b, err = stripJPEG(b)
+		}
+		if err != nil {
+			return fmt.Errorf("StripMetadata: %s: %w", fileName, err)
+		}
+		err = writeFile(fileName, b, true)
+		if err != nil {
+			return err
+		}
+	}
+	err = os.Remove(SidecarName(fileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// stripJPEG drops the DocumentName tag and an XMP segment that holds the
+// fractal namespace.
+func stripJPEG(b []byte) ([]byte, error) {
+	intfc, err := jis.NewJpegMediaParser().ParseBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	sl := intfc.(*jis.SegmentList)
+
+	_, _, err = sl.FindExif()
+	if err == nil {
+		ib, err := sl.ConstructExifBuilder()
+		if err != nil {
+			return nil, err
+		}
+		ifd0Ib, err := exif.GetOrCreateIbFromRootIb(ib, "IFD0")
+		if err != nil {
+			return nil, err
+		}
+		_, err = ifd0Ib.DeleteAll(documentNameTag)
+		if err != nil {
+			return nil, err
+		}
+		err = sl.SetExif(ib)
+		if err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, exif.ErrNoExif) {
+		return nil, err
+	}
+
+	i, s, err := sl.FindXmp()
+	if err == nil && bytes.Contains(s.Data, []byte(XMPNamespace)) {
+		segments := sl.Segments()
+		sl = jis.NewSegmentList(append(segments[:i:i], segments[i+1:]...))
+	}
+
+	var out bytes.Buffer
+	err = sl.Write(&out)
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// The EXIF tag ids of DocumentName, Artist and Copyright.
+const (
+	documentNameTag = 0x010d
+	artistTag       = 0x013b
+	copyrightTag    = 0x8298
+)
+
+// readJPEGXMP returns the XMP packet of a jpeg, or "" if it has none.
+func readJPEGXMP(b []byte) (string, error) {
+	intfc, err := jis.NewJpegMediaParser().ParseBytes(b)
+	if err != nil {
+		return "", err
+	}
+	_, s, err := intfc.(*jis.SegmentList).FindXmp()
+	if errors.Is(err, jis.ErrNoXmp) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(s.Data[len(xmpJPEGPrefix):]), nil
+}
+
+func setJPEGMetadata(b []byte, m *MandelData, cfg *Config) ([]byte, error) {
+	text, err := m.Encode()
+	if err != nil {
+		return nil, err
+	}
+	intfc, err := jis.NewJpegMediaParser().ParseBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	sl := intfc.(*jis.SegmentList)
+
+	ib, err := sl.ConstructExifBuilder()
+	if err != nil {
+		return nil, err
+	}
+	ifd0Ib, err := exif.GetOrCreateIbFromRootIb(ib, "IFD0")
+	if err != nil {
+		return nil, err
+	}
+	// An empty value deletes the tag, so that the EXIF agrees with the
+	// XMP, which leaves it out.
+	tags := []struct {
+		id          uint16
+		name, value string
+	}{
+		{documentNameTag, "DocumentName", string(text)},
+		{artistTag, "Artist", cfg.Artist},
+		{copyrightTag, "Copyright", cfg.Copyright},
+	}
+	for _, tag := range tags {
+		if tag.value == "" {
+			_, err = ifd0Ib.DeleteAll(tag.id)
+		} else {
+			err = ifd0Ib.SetStandardWithName(tag.name, tag.value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = sl.SetExif(ib)
+	if err != nil {
+		return nil, err
+	}
+
+	// The XMP segment replaces an old one, or follows the EXIF segment.
+	xmp := append(append([]byte{}, xmpJPEGPrefix...), m.XMP(cfg)...)
+	if len(xmp) > 0xffff-2 {
+		return nil, errors.New("the XMP packet does not fit in a jpeg segment")
+	}
+	_, s, err := sl.FindXmp()
+	if err == nil {
+		s.Data = xmp
+	} else {
+		i, _, err := sl.FindExif()
+		if err != nil {
+			return nil, err
+		}
+		segments := sl.Segments()
+		segments = append(segments[:i+1:i+1], append([]*jis.Segment{
+			{MarkerId: jis.MARKER_APP1, Data: xmp}}, segments[i+1:]...)...)
+		sl = jis.NewSegmentList(segments)
+	}
+
+	var out bytes.Buffer
+	err = sl.Write(&out)
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// pngChunk is one chunk of a png file, raw is the whole chunk including
+// its length and crc.
+type pngChunk struct {
+	kind string
+	data []byte
+	raw  []byte
+}
+
+func readPNGChunks(b []byte) ([]pngChunk, error) {
+	b = b[len(pngSignature):]
+	var chunks []pngChunk
+	for len(b) > 0 {
+		if len(b) < 12 {
+			return nil, errors.New("truncated png chunk")
+		}
+		n := int(binary.BigEndian.Uint32(b))
+		if n < 0 || 12+n > len(b) {
+			return nil, errors.New("truncated png chunk")
+		}
+		chunks = append(chunks, pngChunk{string(b[4:8]), b[8 : 8+n], b[:12+n]})
+		b = b[12+n:]
+	}
+	return chunks, nil
+}
+
+// readPNGText finds the uncompressed iTXt chunk with the keyword.
+func readPNGText(b []byte, keyword string) (string, bool, error) {
+	chunks, err := readPNGChunks(b)
+	if err != nil {
+		return "", false, err
+	}
+	for _, c := range chunks {
+		if c.kind != "iTXt" {
+			continue
+		}
+		// keyword, 0, compression flag, compression method, language,
+		// 0, translated keyword, 0, text
+		k, rest, ok := bytes.Cut(c.data, []byte{0})
+		if !ok || string(k) != keyword || len(rest) < 2 {
+			continue
+		}
+		if rest[0] != 0 {
+			return "", false, fmt.Errorf("the %s chunk is compressed", keyword)
+		}
+		_, rest, _ = bytes.Cut(rest[2:], []byte{0})
+		_, text, ok := bytes.Cut(rest, []byte{0})
+		if !ok {
+			return "", false, fmt.Errorf("the %s chunk is malformed", keyword)
+		}
+		return string(text), true, nil
+	}
+	return "", false, nil
+}
+
+// setPNGText replaces any iTXt chunk with the keyword by one holding text,
+// placed right after IHDR.
+func setPNGText(b []byte, keyword, text string) ([]byte, error) {
+	data := append([]byte(keyword), 0, 0, 0, 0, 0)
+	data = append(data, text...)
+	return rewritePNGText(b, []string{keyword}, data)
+}
+
+// removePNGText drops the iTXt chunks with the keywords.
+func removePNGText(b []byte, keywords ...string) ([]byte, error) {
+	return rewritePNGText(b, keywords, nil)
+}
+
+// rewritePNGText copies a png without the iTXt chunks with the keywords,
+// adding an iTXt chunk holding data after IHDR unless data is nil.
+func rewritePNGText(b []byte, keywords []string, data []byte) ([]byte, error) {
+	chunks, err := readPNGChunks(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 || chunks[0].kind != "IHDR" {
+		return nil, errors.New("the png does not start with IHDR")
+	}
+
+	out := bytes.NewBuffer(append([]byte{}, pngSignature...))
+	for i, c := range chunks {
+		k, _, _ := bytes.Cut(c.data, []byte{0})
+		if c.kind == "iTXt" && slices.Contains(keywords, string(k)) {
+			continue
+		}
+		out.Write(c.raw)
+		if i == 0 && data != nil {
+			writePNGChunk(out, "iTXt", data)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// writePNGChunk writes one chunk with its length and crc.
+func writePNGChunk(w *bytes.Buffer, kind string, data []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(data)))
+	w.WriteString(kind)
+	w.Write(data)
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(kind))
+	crc.Write(data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
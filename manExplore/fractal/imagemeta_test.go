@@ -0,0 +1,236 @@
+package fractal
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	jis "github.com/dsoprea/go-jpeg-image-structure/v2"
+)
+
+// testPNG is a small png without metadata.
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	err := png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 4, 3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestPNGText(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     [][2]string // keyword, text written in turn
+		remove  []string
+		keyword string
+		want    string
+		wantOK  bool
+	}{
+		{"none", nil, nil, pngKeyword, "", false},
+		{"one", [][2]string{{pngKeyword, `{"X":1}`}}, nil, pngKeyword, `{"X":1}`, true},
+		{"replaced", [][2]string{{pngKeyword, "old"}, {pngKeyword, "new"}}, nil, pngKeyword, "new", true},
+		{"other keyword", [][2]string{{pngKeyword, "json"}, {xmpKeyword, "xmp"}}, nil, pngKeyword, "json", true},
+		{"second keyword", [][2]string{{pngKeyword, "json"}, {xmpKeyword, "xmp"}}, nil, xmpKeyword, "xmp", true},
+		{"utf-8", [][2]string{{pngKeyword, "Rotation 45° – ok"}}, nil, pngKeyword, "Rotation 45° – ok", true},
+		{"removed", [][2]string{{pngKeyword, "json"}, {xmpKeyword, "xmp"}}, []string{pngKeyword, xmpKeyword},
+			xmpKeyword, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := testPNG(t)
+			var err error
+			for _, s := range tt.set {
+				b, err = setPNGText(b, s[0], s[1])
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.remove != nil {
+				b, err = removePNGText(b, tt.remove...)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			text, ok, err := readPNGText(b, tt.keyword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != tt.want || ok != tt.wantOK {
+				t.Errorf("readPNGText = %q, %v, want %q, %v", text, ok, tt.want, tt.wantOK)
+			}
+			if n := bytes.Count(b, []byte("iTXt"+tt.keyword+"\x00")); n > 1 {
+				t.Errorf("%d %s chunks, want at most 1", n, tt.keyword)
+			}
+			// The chunks must keep their crc for other readers.
+			if _, err := png.Decode(bytes.NewReader(b)); err != nil {
+				t.Errorf("png.Decode: %v", err)
+			}
+		})
+	}
+}
+
+func TestPNGTextErrors(t *testing.T) {
+	b := testPNG(t)
+	compressed := append([]byte(pngKeyword), 0, 1, 0, 0, 0)
+	withCompressed, err := rewritePNGText(b, nil, append(compressed, "x"...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		b    []byte
+		err  string
+	}{
+		{"compressed", withCompressed, "compressed"},
+		{"truncated", b[:len(b)-5], "truncated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readPNGText(tt.b, pngKeyword)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want one with %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestPNGMetadataRoundTrip(t *testing.T) {
+	m := NewMandelData(View{X: -0.7436, Y: 0.1318, Scale: 1e-5, Rotation: 12.5, Offset: 0.5,
+		Formula: "tricorn", Palette: "fire"}, 4, 3)
+	m.Author = "someone"
+	m.FileName = "a.png"
+	cfg := &Config{Artist: "someone", Copyright: "CC BY 4.0"}
+
+	var b bytes.Buffer
+	err := EncodePNG(&b, image.NewRGBA(image.Rect(0, 0, 4, 3)), m, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), "a.png")
+	err = os.WriteFile(fileName, b.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadMetadata(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("EncodePNG then ReadMetadata gave\n%+v\nwant\n%+v", got, m)
+	}
+
+	// Writing again replaces the data rather than adding to it.
+	m.Scale, m.Iterations = 2e-6, 2000
+	err = WriteMetadata(fileName, m, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = ReadMetadata(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("WriteMetadata then ReadMetadata gave\n%+v\nwant\n%+v", got, m)
+	}
+	b2, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(b2, []byte("iTXt"+pngKeyword+"\x00")); n != 1 {
+		t.Errorf("%d %s chunks after WriteMetadata, want 1", n, pngKeyword)
+	}
+}
+
+// exifValues returns the IFD0 EXIF tags of a jpeg by name.
+func exifValues(t *testing.T, fileName string) map[string]string {
+	t.Helper()
+	intfc, err := jis.NewJpegMediaParser().ParseFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, exifTags, err := intfc.(*jis.SegmentList).DumpExif()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{}
+	for _, et := range exifTags {
+		if et.IfdPath == "IFD" {
+			values[et.TagName] = et.FormattedFirst
+		}
+	}
+	return values
+}
+
+func TestJPEGArtistReplaced(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "a.jpg")
+	file, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = jpeg.Encode(file, image.NewRGBA(image.Rect(0, 0, 4, 3)), nil)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMandelData(View{X: -0.5, Scale: 0.5}, 4, 3)
+
+	tests := []struct {
+		name string
+		cfg  *Config
+		want map[string]string // Artist and Copyright, "" for none
+	}{
+		{"with artist", &Config{Artist: "someone", Copyright: "CC BY 4.0"},
+			map[string]string{"Artist": "someone", "Copyright": "CC BY 4.0"}},
+		{"new artist", &Config{Artist: "someone else"},
+			map[string]string{"Artist": "someone else", "Copyright": ""}},
+		{"empty config", &Config{}, map[string]string{"Artist": "", "Copyright": ""}},
+		{"nil config", nil, map[string]string{"Artist": "", "Copyright": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := WriteMetadata(fileName, m, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			values := exifValues(t, fileName)
+			for name, want := range tt.want {
+				if values[name] != want {
+					t.Errorf("EXIF %s is %q, want %q", name, values[name], want)
+				}
+			}
+			if values["DocumentName"] == "" {
+				t.Error("the DocumentName tag is gone")
+			}
+			xmp, err := readJPEGXMP(mustRead(t, fileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hasCreator := strings.Contains(xmp, "dc:creator"); hasCreator != (tt.want["Artist"] != "") {
+				t.Errorf("the XMP has dc:creator %v, the EXIF Artist is %q", hasCreator, values["Artist"])
+			}
+		})
+	}
+}
+
+// mustRead returns the contents of fileName.
+func mustRead(t *testing.T, fileName string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -4,41 +4,86 @@ import (
 	"fmt"
 	"image/color"
 	"math"
+	"sync/atomic"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 )
 
+// The catalog of saved images is a package of its own, so the programs
+// that only draw do not carry its database. manExplore plugs it in here.
+var (
+	// ImageSaved, if set, is told the name of every image the explorer
+	// saves.
+	ImageSaved func(fileName string) error
+
+	// ShowGallery, if set, opens the gallery window. Its open continues
+	// exploring from an image.
+	ShowGallery func(win fyne.Window, open func(fileName string) error)
+)
+
 type Fractal struct {
 	currIterations          uint
 	currScale, currX, currY float64
+	currRotation            float64 // Degrees
+
+	// Colouring restored from a saved image. An empty palette keeps the
+	// theme colours.
+	formula, palette string
+	offset           float64
+	shade            func(c complex128) color.RGBA
+
+	export    ExportOptions // Last choices of the export dialog
+	exporting atomic.Bool
+
+	settings Explorer                    // Preferences, with the defaults filled in
+	keys     map[string]func(f *Fractal) // Action of each bound key
 
 	startIterations            uint
 	startScale, startX, startY float64
-
-	window fyne.Window
-	canvas fyne.CanvasObject
+	startRotation              float64
+	startPalette               string
+	startOffset                float64
+
+	window  fyne.Window
+	canvas  fyne.CanvasObject
+	pointer *pointerArea
+	hud     *hud
 }
 
 func (f *Fractal) Layout(objects []fyne.CanvasObject, size fyne.Size) {
 	f.canvas.Resize(size)
+	f.pointer.Resize(size)
+	f.hud.Move(fyne.NewPos(theme.Padding(), theme.Padding()))
+	f.hud.Resize(f.hud.MinSize())
 }
 
 func (f *Fractal) MinSize(objects []fyne.CanvasObject) fyne.Size {
 	return fyne.NewSize(320, 240)
 }
 
-//lint:ignore U1000  See TODO inside the .Show() method.
 func (f *Fractal) refresh() {
-	if f.currScale >= 1.0 {
-		f.currIterations = 100
-	} else {
-		f.currIterations = uint(100 * (1 + math.Pow((math.Log10(1/f.currScale)), 1.25)))
+	f.currIterations = uint(AutoIterations(f.currScale))
+	f.redraw()
+}
+
+// redraw shows the current view without changing the iterations.
+func (f *Fractal) redraw() {
+	var shade func(c complex128) color.RGBA
+	if f.palette != "" || (f.formula != "" && f.formula != DefaultFormula) {
+		var err error
+		shade, err = f.view().Shader()
+		if err != nil {
+			dialog.ShowError(err, f.window)
+		}
 	}
+	f.shade = shade
 
 	f.window.Canvas().Refresh(f.canvas)
+	f.hud.update()
 }
 
 func (f *Fractal) scaleChannel(c float64, start, end uint32) uint8 {
@@ -55,16 +100,37 @@ func (f *Fractal) scaleColor(c float64, start, end color.Color) color.Color {
 	return color.RGBA{f.scaleChannel(c, r1, r2), f.scaleChannel(c, g1, g2), f.scaleChannel(c, b1, b2), 0xff}
 }
 
-func (f *Fractal) mandelbrot(px, py, w, h int) color.Color {
-	drawScale := 3.5 * f.currScale
-	aspect := (float64(h) / float64(w))
-	cRe := ((float64(px)/float64(w))-0.5)*drawScale + f.currX
-	cIm := ((float64(py)/float64(w))-(0.5*aspect))*drawScale - f.currY
+// view returns the current position for the engine.
+func (f *Fractal) view() View {
+	return View{
+		X:          f.currX,
+		Y:          f.currY,
+		Scale:      f.currScale,
+		Rotation:   f.currRotation,
+		Iterations: int(f.currIterations),
+		Formula:    f.formula,
+		Palette:    f.palette,
+		Offset:     f.offset,
+	}
+}
+
+// mandelbrot colours a pixel of v, through shade unless it is nil.
+func (f *Fractal) mandelbrot(v View, shade func(c complex128) color.RGBA, px, py, w, h int) color.Color {
+	p := v.Point(float64(px), float64(py), w, h)
+	if shade != nil {
+		return shade(p)
+	}
+	return f.themeColor(p, uint(v.Iterations))
+}
+
+// themeColor blends the theme colours by the escape count of p.
+func (f *Fractal) themeColor(p complex128, iterations uint) color.Color {
+	cRe, cIm := real(p), imag(p)
 
 	var i uint
 	var x, y, xsq, ysq float64
 
-	for i = 0; i < f.currIterations && (xsq+ysq <= 4); i++ {
+	for i = 0; i < iterations && (xsq+ysq <= 4); i++ {
 		xNew := float64(xsq-ysq) + cRe
 		y = 2*x*y + cIm
 		x = xNew
@@ -73,49 +139,32 @@ func (f *Fractal) mandelbrot(px, py, w, h int) color.Color {
 		ysq = y * y
 	}
 
-	if i == f.currIterations {
+	if i == iterations {
 		return theme.BackgroundColor()
 	}
 
-	mu := (float64(i) / float64(f.currIterations))
+	mu := (float64(i) / float64(iterations))
 	c := math.Sin((mu / 2) * math.Pi)
 
 	return f.scaleColor(c, theme.PrimaryColor(), theme.ForegroundColor())
 }
 
-//lint:ignore U1000 See TODO inside the .Show() method.
+// fractalRune runs the action bound to a typed character.
 func (f *Fractal) fractalRune(r rune) {
-	if r == '+' {
-		f.currScale /= 1.1
-	} else if r == '-' {
-		f.currScale *= 1.1
-	} else if r == 's' {
-		f.reset()
-	} else if r == 'p' {
-		CreateJPG(f)
-	} else {
-		return
+	if run, ok := f.keys[string(r)]; ok {
+		run(f)
 	}
-
-	f.refresh()
 }
 
-//lint:ignore U1000 See TODO inside the .Show() method.
+// fractalKey runs the action bound to a named key. Keys that type a
+// character are left to fractalRune.
 func (f *Fractal) fractalKey(ev *fyne.KeyEvent) {
-	delta := f.currScale * 0.2
-	if ev.Name == fyne.KeyUp {
-		f.currY -= delta
-	} else if ev.Name == fyne.KeyDown {
-		f.currY += delta
-	} else if ev.Name == fyne.KeyLeft {
-		f.currX += delta
-	} else if ev.Name == fyne.KeyRight {
-		f.currX -= delta
-	} else {
+	if len(ev.Name) < 2 {
 		return
 	}
-
-	f.refresh()
+	if run, ok := f.keys[string(ev.Name)]; ok {
+		run(f)
+	}
 }
 
 func (f *Fractal) reset() {
@@ -123,6 +172,8 @@ func (f *Fractal) reset() {
 	f.currScale = f.startScale
 	f.currX = f.startX
 	f.currY = f.startY
+	f.currRotation = f.startRotation
+	f.palette, f.offset = f.startPalette, f.startOffset
 
 	f.refresh()
 }
@@ -130,19 +181,31 @@ func (f *Fractal) reset() {
 // Show loads a Mandelbrot fractal example window for the specified app context
 func Show(win fyne.Window) fyne.CanvasObject {
 	fractal := &Fractal{window: win}
-	fractal.canvas = canvas.NewRasterWithPixels(fractal.mandelbrot)
-
-	fractal.currIterations = 100
-	fractal.currScale = 1.0
-	fractal.currX = -0.75
-	fractal.currY = 0.0
-	// TODO: Register, and unregister, these keys:
-	win.Canvas().SetOnTypedRune(fractal.fractalRune)
-	win.Canvas().SetOnTypedKey(fractal.fractalKey)
-
-	a := theme.PrimaryColor
-	b := theme.ForegroundColor
-	c := theme.BackgroundColor
-	fmt.Printf("%v %v %v\n", a(), b(), c())
-	return container.New(fractal, fractal.canvas)
+	fractal.canvas = canvas.NewRaster(fractal.draw)
+	fractal.pointer = newPointerArea(fractal)
+	fractal.hud = newHUD(fractal)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Println(err)
+		cfg = &Config{}
+	}
+	fractal.settings = cfg.Explorer.WithDefaults()
+	if err := checkKeys(fractal.settings.Keys); err != nil {
+		fmt.Println("Keys:", err)
+		fractal.settings.Keys = DefaultKeys
+	}
+	fractal.setStart()
+	fractal.currIterations = fractal.startIterations
+	fractal.currScale = fractal.startScale
+	fractal.currX = fractal.startX
+	fractal.currY = fractal.startY
+	fractal.palette = fractal.startPalette
+	fractal.redraw()
+
+	fractal.bindKeys()
+	win.SetOnDropped(fractal.dropped)
+	win.Resize(fyne.NewSize(float32(fractal.settings.Width), float32(fractal.settings.Height)))
+
+	return container.New(fractal, fractal.canvas, fractal.pointer, fractal.hud)
 }
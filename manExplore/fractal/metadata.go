@@ -0,0 +1,298 @@
+package fractal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// MandelDataVersion is the version of the MandelData schema written by
+// Encode. Files without a Version field are version 0, which only held
+// Author, FileName, Scale, X and Y (and later Rotation).
+const MandelDataVersion = 1
+
+// Colourings of a saved image. ColoringSmooth is the engine's smoothed
+// escape value through Palette. ColoringTheme is manExplore's on-screen
+// blend of the fyne theme colours by escape count, Colors holds them.
+const (
+	ColoringSmooth = "smooth"
+	ColoringTheme  = "theme"
+)
+
+// MandelData is the fractal metadata stored in saved images. Version 1
+// carries everything needed to render the image again.
+type MandelData struct {
+	Version       int
+	Author        string
+	FileName      string
+	Scale         float64
+	X, Y          float64
+	Rotation      float64 // Degrees
+	Iterations    int
+	Width, Height int
+	Formula       string
+	Coloring      string   // ColoringSmooth or ColoringTheme
+	Palette       string   // Smooth colouring only
+	Offset        float64  // Palette offset, smooth colouring only
+	Samples       int      // Supersampling, 1 is off
+	Colors        []string `json:",omitempty"` // #rrggbb interior, from and to of the theme colouring
+}
+
+// NewMandelData describes a w by h image rendered by the engine.
+func NewMandelData(v View, w, h int) *MandelData {
+	if v.Iterations <= 0 {
+		v.Iterations = AutoIterations(v.Scale)
+	}
+	if v.Formula == "" {
+		v.Formula = DefaultFormula
+	}
+	if v.Palette == "" {
+		v.Palette = DefaultPalette
+	}
+	return &MandelData{
+		Version:    MandelDataVersion,
+		Scale:      v.Scale,
+		X:          v.X,
+		Y:          v.Y,
+		Rotation:   v.Rotation,
+		Iterations: v.Iterations,
+		Width:      w,
+		Height:     h,
+		Formula:    v.Formula,
+		Coloring:   ColoringSmooth,
+		Palette:    v.Palette,
+		Offset:     v.Offset,
+		Samples:    max(v.Samples, 1),
+	}
+}
+
+// View returns the engine view of the image. The engine has no theme
+// colouring, such images come back with the default palette.
+func (m *MandelData) View() View {
+	v := View{
+		X:          m.X,
+		Y:          m.Y,
+		Scale:      m.Scale,
+		Rotation:   m.Rotation,
+		Iterations: m.Iterations,
+		Formula:    m.Formula,
+		Samples:    m.Samples,
+	}
+	if m.Coloring == ColoringSmooth {
+		v.Palette = m.Palette
+		v.Offset = m.Offset
+	}
+	return v
+}
+
+// Encode returns the json stored in images, stamped with the current
+// schema version.
+func (m *MandelData) Encode() ([]byte, error) {
+	md := *m
+	md.Version = MandelDataVersion
+	return json.Marshal(&md)
+}
+
+// String lists the parameters one per line for people to read.
+func (m *MandelData) String() string {
+	var b strings.Builder
+	line := func(name, format string, a ...any) {
+		fmt.Fprintf(&b, "%-11s "+format+"\n", append([]any{name}, a...)...)
+	}
+	line("FileName", "%s", m.FileName)
+	line("Author", "%s", m.Author)
+	line("Version", "%d", m.Version)
+	line("Centre", "%s, %s", formatFloat(m.X), formatFloat(m.Y))
+	line("Scale", "%s", formatFloat(m.Scale))
+	line("Rotation", "%s°", formatFloat(m.Rotation))
+	line("Iterations", "%d", m.Iterations)
+	line("Size", "%d x %d", m.Width, m.Height)
+	line("Formula", "%s", m.Formula)
+	switch m.Coloring {
+	case ColoringSmooth:
+		line("Coloring", "%s, palette %s, offset %s", m.Coloring, m.Palette, formatFloat(m.Offset))
+	case ColoringTheme:
+		line("Coloring", "%s", strings.Join(append([]string{m.Coloring}, m.Colors...), " "))
+	default:
+		line("Coloring", "%s", m.Coloring)
+	}
+	line("Samples", "%d", m.Samples)
+	return b.String()
+}
+
+// formatFloat prints every digit needed to get f back.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Depth is the number of decades the view at scale is zoomed in from the
+// whole set at scale 1.
+func Depth(scale float64) float64 {
+	return math.Log10(1 / scale)
+}
+
+// Set changes the field with the given name, in any case, to value
+// parsed for the field's type. Colors takes a comma separated list. Call
+// Check once all fields are set.
+func (m *MandelData) Set(name, value string) error {
+	v := reflect.ValueOf(m).Elem()
+	f := v.FieldByNameFunc(func(field string) bool { return strings.EqualFold(field, name) })
+	if !f.IsValid() {
+		return fmt.Errorf("Set: MandelData has no field %q", name)
+	}
+	switch f.Kind() {
+	case reflect.Float64:
+		x, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("Set: %s: %w", name, err)
+		}
+		f.SetFloat(x)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("Set: %s: %w", name, err)
+		}
+		f.SetInt(int64(n))
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Slice:
+		var list []string
+		if value != "" {
+			list = strings.Split(value, ",")
+		}
+		f.Set(reflect.ValueOf(list))
+	}
+	return nil
+}
+
+// mandelDataV0 is the schema written before versioning.
+type mandelDataV0 struct {
+	Author   string
+	FileName string
+	Scale    float64
+	X, Y     float64
+	Rotation float64
+}
+
+// DecodeMandelData parses the json stored in an image. Unknown fields,
+// missing parameters and values that cannot be rendered are errors.
+// Version 0 data is upgraded: manExplore wrote it from a 3840 x 2160
+// render with the iterations of AutoIterations and its theme colouring.
+func DecodeMandelData(b []byte) (*MandelData, error) {
+	var head struct {
+		Version *int
+	}
+	err := json.Unmarshal(b, &head)
+	if err != nil {
+		return nil, fmt.Errorf("DecodeMandelData: %w", err)
+	}
+
+	m := &MandelData{}
+	switch {
+	case head.Version == nil:
+		v0 := &mandelDataV0{}
+		err = decodeStrict(b, v0)
+		if err != nil {
+			return nil, fmt.Errorf("DecodeMandelData: version 0: %w", err)
+		}
+		m = &MandelData{
+			Version:    MandelDataVersion,
+			Author:     v0.Author,
+			FileName:   v0.FileName,
+			Scale:      v0.Scale,
+			X:          v0.X,
+			Y:          v0.Y,
+			Rotation:   v0.Rotation,
+			Iterations: AutoIterations(v0.Scale),
+			Width:      PX,
+			Height:     PY,
+			Formula:    DefaultFormula,
+			Coloring:   ColoringTheme,
+			Samples:    1,
+		}
+	case *head.Version == MandelDataVersion:
+		err = decodeStrict(b, m)
+		if err != nil {
+			return nil, fmt.Errorf("DecodeMandelData: version %d: %w", *head.Version, err)
+		}
+	case *head.Version > MandelDataVersion:
+		return nil, fmt.Errorf("DecodeMandelData: version %d was written by a newer program, "+
+			"this one reads up to version %d", *head.Version, MandelDataVersion)
+	default:
+		return nil, fmt.Errorf("DecodeMandelData: unknown version %d", *head.Version)
+	}
+
+	err = m.Check()
+	if err != nil {
+		return nil, fmt.Errorf("DecodeMandelData: %w", err)
+	}
+	return m, nil
+}
+
+// decodeStrict unmarshals a single json object and refuses fields that v
+// does not have.
+func decodeStrict(b []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("trailing data after the json object")
+	}
+	return nil
+}
+
+// Check makes sure the parameters describe an image that can be rendered.
+func (m *MandelData) Check() error {
+	fields := []struct {
+		name  string
+		value float64
+	}{
+		{"Scale", m.Scale},
+		{"X", m.X},
+		{"Y", m.Y},
+		{"Rotation", m.Rotation},
+		{"Offset", m.Offset},
+	}
+	for _, f := range fields {
+		if math.IsNaN(f.value) || math.IsInf(f.value, 0) {
+			return fmt.Errorf("%s is %g", f.name, f.value)
+		}
+	}
+	if m.Scale <= 0 {
+		return fmt.Errorf("Scale %g must be positive", m.Scale)
+	}
+	if m.Iterations < 1 {
+		return fmt.Errorf("Iterations %d must be positive", m.Iterations)
+	}
+	if m.Width < 1 || m.Height < 1 {
+		return fmt.Errorf("Width and Height %d x %d must be positive", m.Width, m.Height)
+	}
+	if m.Samples < 1 {
+		return fmt.Errorf("Samples %d must be positive", m.Samples)
+	}
+	if _, err := LookupFormula(m.Formula); err != nil {
+		return err
+	}
+	switch m.Coloring {
+	case ColoringSmooth:
+		if _, err := LookupPalette(m.Palette); err != nil {
+			return err
+		}
+	case ColoringTheme:
+		if len(m.Colors) != 0 && len(m.Colors) != 3 {
+			return fmt.Errorf("Colors has %d entries, the theme colouring needs 3", len(m.Colors))
+		}
+	default:
+		return fmt.Errorf("unknown Coloring %q, use %s or %s", m.Coloring,
+			ColoringSmooth, ColoringTheme)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package fractal
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeMandelData(t *testing.T) {
+	v1 := &MandelData{
+		Version:    MandelDataVersion,
+		Author:     "someone",
+		FileName:   "a.jpg",
+		Scale:      1e-5,
+		X:          -0.7436,
+		Y:          0.1318,
+		Rotation:   30,
+		Iterations: 1000,
+		Width:      1920,
+		Height:     1080,
+		Formula:    DefaultFormula,
+		Coloring:   ColoringSmooth,
+		Palette:    DefaultPalette,
+		Offset:     0.25,
+		Samples:    2,
+	}
+	encoded, err := v1.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		json string
+		want *MandelData
+		err  string // Part of the error, empty for none
+	}{
+		{
+			name: "version 1",
+			json: string(encoded),
+			want: v1,
+		},
+		{
+			name: "version 0 is upgraded",
+			json: `{"Author":"John S. Dey Jr.","FileName":"231105@101010.jpg","Scale":0.001,"X":-0.75,"Y":0.1}`,
+			want: &MandelData{
+				Version:    MandelDataVersion,
+				Author:     "John S. Dey Jr.",
+				FileName:   "231105@101010.jpg",
+				Scale:      0.001,
+				X:          -0.75,
+				Y:          0.1,
+				Iterations: AutoIterations(0.001),
+				Width:      PX,
+				Height:     PY,
+				Formula:    DefaultFormula,
+				Coloring:   ColoringTheme,
+				Samples:    1,
+			},
+		},
+		{
+			name: "version 0 with rotation",
+			json: `{"Author":"","FileName":"a.jpg","Scale":0.5,"X":0,"Y":0,"Rotation":-45}`,
+			want: &MandelData{
+				Version:    MandelDataVersion,
+				FileName:   "a.jpg",
+				Scale:      0.5,
+				Rotation:   -45,
+				Iterations: AutoIterations(0.5),
+				Width:      PX,
+				Height:     PY,
+				Formula:    DefaultFormula,
+				Coloring:   ColoringTheme,
+				Samples:    1,
+			},
+		},
+		{
+			name: "unknown field in version 0",
+			json: `{"Scale":0.5,"X":0,"Y":0,"Zoom":2}`,
+			err:  `unknown field "Zoom"`,
+		},
+		{
+			name: "unknown field in version 1",
+			json: strings.Replace(string(encoded), `"Samples"`, `"Smaples"`, 1),
+			err:  `unknown field "Smaples"`,
+		},
+		{
+			name: "trailing data",
+			json: string(encoded) + `{}`,
+			err:  "after top-level value",
+		},
+		{
+			name: "newer version",
+			json: `{"Version":99}`,
+			err:  "newer program",
+		},
+		{
+			name: "unknown version",
+			json: `{"Version":-1}`,
+			err:  "unknown version -1",
+		},
+		{
+			name: "not json",
+			json: `Scale=1`,
+			err:  "invalid character",
+		},
+		{
+			name: "scale not positive",
+			json: `{"Scale":0,"X":0,"Y":0}`,
+			err:  "Scale 0 must be positive",
+		},
+		{
+			name: "unknown formula",
+			json: strings.Replace(string(encoded), DefaultFormula, "julia", 1),
+			err:  "julia",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := DecodeMandelData([]byte(tt.json))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one with %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(m, tt.want) {
+				t.Errorf("got\n%+v\nwant\n%+v", m, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	tests := []struct {
+		name   string
+		change func(m *MandelData)
+		err    string // Part of the error, empty for none
+	}{
+		{"valid", func(m *MandelData) {}, ""},
+		{"NaN offset", func(m *MandelData) { m.Offset = nan }, "Offset is NaN"},
+		{"infinite rotation", func(m *MandelData) { m.Rotation = -inf }, "Rotation is -Inf"},
+		{"the first bad field is named", func(m *MandelData) { m.Offset, m.Y, m.X = nan, inf, nan }, "X is NaN"},
+		{"zero iterations", func(m *MandelData) { m.Iterations = 0 }, "Iterations 0"},
+		{"theme with two colours", func(m *MandelData) {
+			m.Coloring, m.Colors = ColoringTheme, []string{"#000000", "#ffffff"}
+		}, "Colors has 2 entries"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run every case a few times, an order that changed would show.
+			for i := 0; i < 20; i++ {
+				m := NewMandelData(View{Scale: 0.5}, 4, 3)
+				tt.change(m)
+				err := m.Check()
+				if tt.err == "" {
+					if err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one with %q", err, tt.err)
+				}
+			}
+		})
+	}
+}
+
+func TestMandelDataSet(t *testing.T) {
+	tests := []struct {
+		name, value string
+		check       func(m *MandelData) any
+		want        any
+		err         string // Part of the error, empty for none
+	}{
+		{"Scale", "1e-9", func(m *MandelData) any { return m.Scale }, 1e-9, ""},
+		{"x", "-0.75", func(m *MandelData) any { return m.X }, -0.75, ""},
+		{"ROTATION", "-30.5", func(m *MandelData) any { return m.Rotation }, -30.5, ""},
+		{"iterations", "5000", func(m *MandelData) any { return m.Iterations }, 5000, ""},
+		{"Author", "A. N. Other", func(m *MandelData) any { return m.Author }, "A. N. Other", ""},
+		{"palette", "fire", func(m *MandelData) any { return m.Palette }, "fire", ""},
+		{"Colors", "#000000,#ff0000,#ffffff", func(m *MandelData) any { return m.Colors },
+			[]string{"#000000", "#ff0000", "#ffffff"}, ""},
+		{"Colors", "", func(m *MandelData) any { return m.Colors }, []string(nil), ""},
+		{"Zoom", "2", nil, nil, `no field "Zoom"`},
+		{"Scale", "big", nil, nil, "Scale"},
+		{"Iterations", "1.5", nil, nil, "Iterations"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			m := NewMandelData(View{Scale: 1}, 4, 3)
+			m.Colors = []string{"#010101", "#020202", "#030303"}
+			err := m.Set(tt.name, tt.value)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one with %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.check(m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %#v, want %#v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package fractal
+
+import (
+	"errors"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+)
+
+// SetFractal moves the explorer to the view stored in a saved image. The
+// stored iterations are kept until the next zoom, and s returns here.
+func (f *Fractal) SetFractal(m *MandelData) {
+	f.currScale = m.Scale
+	f.currX = m.X
+	f.currY = m.Y
+	f.currRotation = m.Rotation
+	f.currIterations = uint(m.Iterations)
+	f.formula = m.Formula
+	f.palette, f.offset = "", 0
+	if m.Coloring == ColoringSmooth {
+		f.palette, f.offset = m.Palette, m.Offset
+	}
+
+	f.startScale = f.currScale
+	f.startX = f.currX
+	f.startY = f.currY
+	f.startRotation = f.currRotation
+	f.startPalette, f.startOffset = f.palette, f.offset
+	f.startIterations = f.currIterations
+
+	f.redraw()
+}
+
+// Open restores the view stored in a jpeg, png or the sidecar of a tiff.
+func (f *Fractal) Open(fileName string) error {
+	md, err := ReadMetadata(fileName)
+	if err != nil {
+		return err
+	}
+	f.SetFractal(md)
+	f.window.SetTitle(fmt.Sprintf("Mandelbrot - %s", md.FileName))
+	return nil
+}
+
+// showOpen asks for an image to continue from.
+func (f *Fractal) showOpen() {
+	open := dialog.NewFileOpen(func(r fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, f.window)
+			return
+		}
+		if r == nil {
+			return // Cancelled
+		}
+		r.Close()
+		f.openURI(r.URI())
+	}, f.window)
+	open.SetFilter(storage.NewExtensionFileFilter(ImageExtensions))
+	open.Show()
+}
+
+// showGallery opens the gallery of saved images, when there is one.
+func (f *Fractal) showGallery() {
+	if ShowGallery != nil {
+		ShowGallery(f.window, f.Open)
+	}
+}
+
+// dropped opens the first image dropped on the window.
+func (f *Fractal) dropped(_ fyne.Position, uris []fyne.URI) {
+	if len(uris) > 0 {
+		f.openURI(uris[0])
+	}
+}
+
+func (f *Fractal) openURI(uri fyne.URI) {
+	if uri.Scheme() != "file" {
+		dialog.ShowError(errors.New("only local files can be opened"), f.window)
+		return
+	}
+	err := f.Open(uri.Path())
+	if err != nil {
+		dialog.ShowError(err, f.window)
+	}
+}
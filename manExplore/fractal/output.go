@@ -0,0 +1,250 @@
+package fractal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/tiff"
+)
+
+// Images are written to a temporary file in their folder first and only
+// get their name once complete, so a failed save leaves no half written
+// image behind.
+
+const (
+	DefaultOutputDir    = "./pic"
+	DefaultNameTemplate = "{time}"
+)
+
+// templateFields are the placeholders of a naming template.
+var templateFields = map[string]func(m *MandelData) string{
+	"time":     func(*MandelData) string { return Time2str() },
+	"x":        func(m *MandelData) string { return formatFloat(m.X) },
+	"y":        func(m *MandelData) string { return formatFloat(m.Y) },
+	"scale":    func(m *MandelData) string { return formatFloat(m.Scale) },
+	"depth":    func(m *MandelData) string { return strconv.FormatFloat(Depth(m.Scale), 'f', 1, 64) },
+	"rotation": func(m *MandelData) string { return formatFloat(m.Rotation) },
+	"iter":     func(m *MandelData) string { return strconv.Itoa(m.Iterations) },
+	"size":     func(m *MandelData) string { return fmt.Sprintf("%dx%d", m.Width, m.Height) },
+	"formula":  func(m *MandelData) string { return m.Formula },
+	"palette": func(m *MandelData) string {
+		if m.Coloring == ColoringSmooth {
+			return m.Palette
+		}
+		return m.Coloring
+	},
+}
+
+// ExpandName fills in a naming template for the image m, without the
+// extension. Placeholders are written in braces: {time} (YYMMDD@HHMMSS),
+// {x}, {y}, {scale}, {depth}, {rotation}, {iter}, {size}, {formula} and
+// {palette}. A / in the template makes sub folders.
+func ExpandName(template string, m *MandelData) (string, error) {
+	var b strings.Builder
+	rest := template
+	for {
+		before, after, found := strings.Cut(rest, "{")
+		b.WriteString(before)
+		if !found {
+			break
+		}
+		name, after, found := strings.Cut(after, "}")
+		if !found {
+			return "", fmt.Errorf("ExpandName: %q has an unclosed {", template)
+		}
+		field, ok := templateFields[name]
+		if !ok {
+			return "", fmt.Errorf("ExpandName: %q has an unknown field {%s}", template, name)
+		}
+		b.WriteString(strings.ReplaceAll(field(m), string(filepath.Separator), "_"))
+		rest = after
+	}
+	if strings.TrimSpace(b.String()) == "" {
+		return "", fmt.Errorf("ExpandName: %q gives an empty name", template)
+	}
+	return b.String(), nil
+}
+
+// SaveImage writes img with m in the output folder of cfg under a name
+// from its template, and returns the file name. An existing image is
+// never replaced: a name that is taken gets -2, -3, ... added.
+func SaveImage(img image.Image, format string, quality int, m *MandelData, cfg *Config) (string, error) {
+	dir := cfg.OutputDir
+	if dir == "" {
+		dir = DefaultOutputDir
+	}
+	template := cfg.NameTemplate
+	if template == "" {
+		template = DefaultNameTemplate
+	}
+	name, err := ExpandName(template, m)
+	if err != nil {
+		return "", err
+	}
+	ext := "." + format
+	switch format {
+	case "jpeg":
+		ext = ".jpg"
+	case "tiff":
+		ext = ".tif"
+	}
+	base := filepath.Join(dir, name)
+	err = os.MkdirAll(filepath.Dir(base), 0755)
+	if err != nil {
+		return "", fmt.Errorf("SaveImage: %w", err)
+	}
+
+	for n := 1; n < 1000; n++ {
+		fileName := base + ext
+		if n > 1 {
+			fileName = fmt.Sprintf("%s-%d%s", base, n, ext)
+		}
+		if _, err := os.Lstat(fileName); err == nil {
+			continue
+		}
+		m.FileName = filepath.Base(fileName)
+		var b bytes.Buffer
+		err = EncodeImage(&b, img, format, quality, m, cfg)
+		if err != nil {
+			return "", fmt.Errorf("SaveImage: %w", err)
+		}
+		err = writeFile(fileName, b.Bytes(), false)
+		if errors.Is(err, os.ErrExist) {
+			continue // Taken since the Lstat
+		}
+		if err != nil {
+			return "", fmt.Errorf("SaveImage: %w", err)
+		}
+		if cfg.Sidecar || format == "tiff" {
+			err = WriteSidecar(fileName, m, cfg)
+			if err != nil {
+				return fileName, fmt.Errorf("SaveImage: %w", err)
+			}
+		}
+		return fileName, nil
+	}
+	return "", fmt.Errorf("SaveImage: no free name for %s%s", base, ext)
+}
+
+// WriteImage writes img with m to fileName, replacing any file there in
+// one step, and the sidecar if cfg asks for one or the image is a tiff.
+func WriteImage(fileName string, img image.Image, format string, quality int, m *MandelData, cfg *Config) error {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	var b bytes.Buffer
+	err := EncodeImage(&b, img, format, quality, m, cfg)
+	if err != nil {
+		return fmt.Errorf("WriteImage: %w", err)
+	}
+	err = writeFile(fileName, b.Bytes(), true)
+	if err != nil {
+		return fmt.Errorf("WriteImage: %w", err)
+	}
+	if cfg.Sidecar || format == "tiff" {
+		return WriteSidecar(fileName, m, cfg)
+	}
+	return nil
+}
+
+// EncodeImage writes img as a png or a jpeg of the given quality, 0 is
+// the default, carrying m. A tiff is written without metadata, it keeps
+// m in its sidecar.
+func EncodeImage(w io.Writer, img image.Image, format string, quality int, m *MandelData, cfg *Config) error {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	switch format {
+	case "png":
+		return EncodePNG(w, img, m, cfg)
+	case "jpeg":
+		if quality <= 0 {
+			quality = jpeg.DefaultQuality
+		}
+		var buf bytes.Buffer
+		err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+		if err != nil {
+			return err
+		}
+		b, err := setJPEGMetadata(buf.Bytes(), m, cfg)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	case "tiff":
+		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+	}
+	return fmt.Errorf("EncodeImage: unknown format %q, use png, jpeg or tiff", format)
+}
+
+// writeFile writes b to a temporary file next to fileName and then gives
+// it that name. A replaced file keeps its permissions. Unless replace is
+// set an existing file is kept and the error is os.ErrExist.
+func writeFile(fileName string, b []byte, replace bool) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(fileName); err == nil && replace {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err != nil {
+		return err
+	}
+
+	if replace {
+		return os.Rename(tmp.Name(), fileName)
+	}
+	// A hard link fails rather than replace an existing file.
+	err = os.Link(tmp.Name(), fileName)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s: %w", fileName, os.ErrExist)
+	}
+	if err != nil {
+		// FAT, many network shares and some sandboxes have no hard links.
+		return createFile(fileName, b)
+	}
+	return nil
+}
+
+// createFile writes b to a new file, failing with os.ErrExist if fileName
+// is taken. A failed write removes the file again.
+func createFile(fileName string, b []byte) error {
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(fileName)
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package fractal
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestExpandName(t *testing.T) {
+	m := NewMandelData(View{X: -0.7436, Y: 0.1318, Scale: 1e-5, Rotation: 45, Iterations: 1000,
+		Formula: "burningship", Palette: "fire"}, 1920, 1080)
+	theme := NewMandelData(View{X: 0, Y: 0, Scale: 0.5}, 3840, 2160)
+	theme.Coloring = ColoringTheme
+
+	tests := []struct {
+		template string
+		m        *MandelData
+		want     string // A regular expression for the whole name
+		err      string // Part of the error, empty for none
+	}{
+		{"plain", m, `plain`, ""},
+		{"{time}", m, `\d{6}@\d{6}`, ""},
+		{"{x}_{y}", m, `-0\.7436_0\.1318`, ""},
+		{"z{scale}-d{depth}", m, `z1e-05-d5\.0`, ""},
+		{"{rotation}deg {iter}", m, `45deg 1000`, ""},
+		{"{size}", m, `1920x1080`, ""},
+		{"{formula}/{palette}", m, `burningship/fire`, ""},
+		{"{palette}", theme, `theme`, ""},
+		{"{formula}-{time}", theme, `mandelbrot-\d{6}@\d{6}`, ""},
+		{"{zoom}", m, ``, "unknown field {zoom}"},
+		{"a{x", m, ``, "unclosed {"},
+		{"  ", m, ``, "empty name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			name, err := ExpandName(tt.template, tt.m)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one with %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !regexp.MustCompile(`^` + tt.want + `$`).MatchString(name) {
+				t.Errorf("ExpandName(%q) = %q, want %s", tt.template, name, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveImageNames(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	cfg := &Config{OutputDir: dir, NameTemplate: "{formula}/view"}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"png", "mandelbrot/view.png"},
+		{"png", "mandelbrot/view-2.png"},
+		{"png", "mandelbrot/view-3.png"},
+		{"jpeg", "mandelbrot/view.jpg"},
+		{"jpeg", "mandelbrot/view-2.jpg"},
+		{"tiff", "mandelbrot/view.tif"},
+	}
+	for _, tt := range tests {
+		m := NewMandelData(View{Scale: 0.5}, 4, 3)
+		fileName, err := SaveImage(img, tt.format, 0, m, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := filepath.Join(dir, tt.want)
+		if fileName != want {
+			t.Fatalf("SaveImage gave %s, want %s", fileName, want)
+		}
+		got, err := ReadMetadata(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.FileName != filepath.Base(want) {
+			t.Errorf("%s stores FileName %s", tt.want, got.FileName)
+		}
+	}
+
+	// The first file is never replaced.
+	first := filepath.Join(dir, "mandelbrot", "view.png")
+	if _, err := ReadMetadata(first); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(SidecarName(filepath.Join(dir, "mandelbrot", "view.tif"))); err != nil {
+		t.Errorf("the tiff has no sidecar: %v", err)
+	}
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(filepath.Join(dir, "mandelbrot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			t.Errorf("temporary file %s left behind", e.Name())
+		}
+	}
+}
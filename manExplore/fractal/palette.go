@@ -0,0 +1,115 @@
+package fractal
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// Palette maps a normalised escape value in [0, 1] to a colour. Points that
+// never escape are painted Interior.
+type Palette struct {
+	Interior color.RGBA
+	Color    func(mu float64) color.RGBA
+}
+
+// DefaultPalette is used when a View does not name one.
+const DefaultPalette = "hsv"
+
+var palettes = map[string]*Palette{
+	// The colouring used by manSinglePNG and manMovie.
+	"hsv": {
+		Interior: color.RGBA{255, 99, 0, 255},
+		Color: func(mu float64) color.RGBA {
+			return hsvToRGB(360*mu, 1, 1)
+		},
+	},
+	// The fixed colours of the manMovie copy of Fractal.
+	"classic": {
+		Interior: color.RGBA{23, 23, 24, 255},
+		Color: func(mu float64) color.RGBA {
+			c := math.Sin((mu / 2) * math.Pi)
+			return blend(c, color.RGBA{41, 111, 126, 255}, color.RGBA{243, 243, 243, 255})
+		},
+	},
+	"fire": {
+		Interior: color.RGBA{0, 0, 0, 255},
+		Color: func(mu float64) color.RGBA {
+			c := math.Sqrt(mu)
+			if c < 0.5 {
+				return blend(2*c, color.RGBA{32, 0, 0, 255}, color.RGBA{230, 60, 0, 255})
+			}
+			return blend(2*c-1, color.RGBA{230, 60, 0, 255}, color.RGBA{255, 240, 160, 255})
+		},
+	},
+	"grey": {
+		Interior: color.RGBA{0, 0, 0, 255},
+		Color: func(mu float64) color.RGBA {
+			return blend(math.Sqrt(mu), color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255})
+		},
+	},
+}
+
+// LookupPalette returns the palette registered under name. An empty name
+// selects DefaultPalette.
+func LookupPalette(name string) (*Palette, error) {
+	if name == "" {
+		name = DefaultPalette
+	}
+	p, ok := palettes[name]
+	if !ok {
+		return nil, fmt.Errorf("LookupPalette: unknown palette %q", name)
+	}
+	return p, nil
+}
+
+// PaletteNames lists the registered palettes in alphabetical order.
+func PaletteNames() []string {
+	names := make([]string, 0, len(palettes))
+	for name := range palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// blend linearly interpolates between two colours, c is in [0, 1].
+func blend(c float64, start, end color.RGBA) color.RGBA {
+	ch := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + c*(float64(b)-float64(a))))
+	}
+	return color.RGBA{ch(start.R, end.R), ch(start.G, end.G), ch(start.B, end.B), 0xff}
+}
+
+// hsvToRGB converts an HSV triple to an RGB colour. Out of range inputs are
+// clamped rather than reported, since they only arise from rounding.
+func hsvToRGB(h, s, v float64) color.RGBA {
+	h = math.Mod(math.Max(h, 0), 360)
+	s = math.Max(0, math.Min(1, s))
+	v = math.Max(0, math.Min(1, v))
+
+	C := v * s
+	X := C * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - C
+	var Rnot, Gnot, Bnot float64
+	switch {
+	case 0 <= h && h < 60:
+		Rnot, Gnot, Bnot = C, X, 0
+	case 60 <= h && h < 120:
+		Rnot, Gnot, Bnot = X, C, 0
+	case 120 <= h && h < 180:
+		Rnot, Gnot, Bnot = 0, C, X
+	case 180 <= h && h < 240:
+		Rnot, Gnot, Bnot = 0, X, C
+	case 240 <= h && h < 300:
+		Rnot, Gnot, Bnot = X, 0, C
+	case 300 <= h && h < 360:
+		Rnot, Gnot, Bnot = C, 0, X
+	}
+	r := uint8(math.Round((Rnot + m) * 255))
+	g := uint8(math.Round((Gnot + m) * 255))
+	b := uint8(math.Round((Bnot + m) * 255))
+
+	return color.RGBA{r, g, b, 255}
+}
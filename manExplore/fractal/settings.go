@@ -0,0 +1,270 @@
+package fractal
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// DefaultKeys binds every action of the explorer to a key. A key is a
+// single character, as typed, or the name of a key such as Up or F1.
+var DefaultKeys = map[string]string{
+	"ZoomIn":      "+",
+	"ZoomOut":     "-",
+	"RotateLeft":  "<",
+	"RotateRight": ">",
+	"PanUp":       string(fyne.KeyUp),
+	"PanDown":     string(fyne.KeyDown),
+	"PanLeft":     string(fyne.KeyLeft),
+	"PanRight":    string(fyne.KeyRight),
+	"Reset":       "s",
+	"Export":      "p",
+	"Open":        "o",
+	"Gallery":     "g",
+	"Settings":    ",",
+	"HUD":         "h",
+}
+
+// action is what a key does.
+type action struct {
+	name string
+	run  func(f *Fractal)
+}
+
+// actions are in the order of the settings dialog. They are set in init,
+// as the settings dialog refers back to them.
+var actions []action
+
+func init() {
+	actions = []action{
+		{"ZoomIn", func(f *Fractal) { f.zoom(1 / f.settings.ZoomStep) }},
+		{"ZoomOut", func(f *Fractal) { f.zoom(f.settings.ZoomStep) }},
+		{"RotateLeft", func(f *Fractal) { f.rotate(f.settings.RotateStep) }},
+		{"RotateRight", func(f *Fractal) { f.rotate(-f.settings.RotateStep) }},
+		{"PanUp", func(f *Fractal) { f.pan(0, -1) }},
+		{"PanDown", func(f *Fractal) { f.pan(0, 1) }},
+		{"PanLeft", func(f *Fractal) { f.pan(1, 0) }},
+		{"PanRight", func(f *Fractal) { f.pan(-1, 0) }},
+		{"Reset", (*Fractal).reset},
+		{"Export", (*Fractal).showExport},
+		{"Open", (*Fractal).showOpen},
+		{"Gallery", (*Fractal).showGallery},
+		{"Settings", (*Fractal).showSettings},
+		{"HUD", (*Fractal).toggleHUD},
+	}
+}
+
+// namedKeys are the keys that are bound by name rather than character.
+var namedKeys = []fyne.KeyName{
+	fyne.KeyUp, fyne.KeyDown, fyne.KeyLeft, fyne.KeyRight,
+	fyne.KeyHome, fyne.KeyEnd, fyne.KeyPageUp, fyne.KeyPageDown,
+	fyne.KeyInsert, fyne.KeyDelete, fyne.KeyBackspace, fyne.KeyTab,
+	fyne.KeyEscape, fyne.KeyReturn, fyne.KeyEnter,
+	fyne.KeyF1, fyne.KeyF2, fyne.KeyF3, fyne.KeyF4, fyne.KeyF5, fyne.KeyF6,
+	fyne.KeyF7, fyne.KeyF8, fyne.KeyF9, fyne.KeyF10, fyne.KeyF11, fyne.KeyF12,
+}
+
+// checkKeys makes sure every key is valid and bound once.
+func checkKeys(keys map[string]string) error {
+	used := map[string]string{}
+	for _, a := range actions {
+		key := keys[a.name]
+		if utf8.RuneCountInString(key) != 1 && !slices.Contains(namedKeys, fyne.KeyName(key)) {
+			return fmt.Errorf("%s: %q is neither one character nor a key name like Up or F1", a.name, key)
+		}
+		if other, ok := used[key]; ok {
+			return fmt.Errorf("%q is bound to both %s and %s", key, other, a.name)
+		}
+		used[key] = a.name
+	}
+	return nil
+}
+
+// bindKeys registers the key handlers with the bindings of the settings.
+func (f *Fractal) bindKeys() {
+	f.keys = map[string]func(f *Fractal){}
+	for _, a := range actions {
+		f.keys[f.settings.Keys[a.name]] = a.run
+	}
+	f.window.Canvas().SetOnTypedRune(f.fractalRune)
+	f.window.Canvas().SetOnTypedKey(f.fractalKey)
+}
+
+// unbindKeys stops the keys from moving the view, while a dialog that
+// takes typing is open.
+func (f *Fractal) unbindKeys() {
+	f.window.Canvas().SetOnTypedRune(nil)
+	f.window.Canvas().SetOnTypedKey(nil)
+}
+
+func (f *Fractal) zoom(factor float64) {
+	f.currScale *= factor
+	f.refresh()
+}
+
+func (f *Fractal) rotate(degrees float64) {
+	f.currRotation = math.Mod(f.currRotation+degrees, 360)
+	f.refresh()
+}
+
+// pan moves the view by PanStep of the scale in the direction dx, dy.
+func (f *Fractal) pan(dx, dy float64) {
+	delta := f.currScale * f.settings.PanStep
+	dx, dy = dx*delta, dy*delta
+
+	// Keep the arrows moving along the screen when the view is rotated.
+	sin, cos := math.Sincos(f.currRotation * math.Pi / 180)
+	f.currX += dx*cos + dy*sin
+	f.currY += -dx*sin + dy*cos
+
+	f.refresh()
+}
+
+// setStart makes the settings' view and palette the ones reset returns
+// to.
+func (f *Fractal) setStart() {
+	s := f.settings
+	f.startScale, f.startX, f.startY = s.Scale, s.X, s.Y
+	f.startRotation = 0
+	f.startPalette, f.startOffset = s.Palette, 0
+	f.startIterations = uint(AutoIterations(s.Scale))
+}
+
+// showSettings edits the preferences and saves them in the configuration.
+func (f *Fractal) showSettings() {
+	cfg, err := LoadConfig()
+	if err != nil {
+		dialog.ShowError(err, f.window)
+		return
+	}
+	s := cfg.Explorer.WithDefaults()
+
+	number := func(v float64) *widget.Entry {
+		e := widget.NewEntry()
+		e.SetText(formatFloat(v))
+		return e
+	}
+	x, y, scale := number(s.X), number(s.Y), number(s.Scale)
+	current := widget.NewButton("Use the current view", func() {
+		x.SetText(formatFloat(f.currX))
+		y.SetText(formatFloat(f.currY))
+		scale.SetText(formatFloat(f.currScale))
+	})
+	palette := widget.NewSelect(append([]string{themeColors}, PaletteNames()...), nil)
+	palette.SetSelected(themeColors)
+	if s.Palette != "" {
+		palette.SetSelected(s.Palette)
+	}
+	zoomStep, panStep, rotateStep := number(s.ZoomStep), number(s.PanStep), number(s.RotateStep)
+	width, height := number(float64(s.Width)), number(float64(s.Height))
+	outputDir, template := widget.NewEntry(), widget.NewEntry()
+	outputDir.SetText(cfg.OutputDir)
+	outputDir.SetPlaceHolder(DefaultOutputDir)
+	template.SetText(cfg.NameTemplate)
+	template.SetPlaceHolder(DefaultNameTemplate)
+
+	view := widget.NewForm(
+		widget.NewFormItem("Start x", x),
+		widget.NewFormItem("Start y", y),
+		widget.NewFormItem("Start scale", scale),
+		widget.NewFormItem("", current),
+		widget.NewFormItem("Palette", palette),
+		widget.NewFormItem("Zoom step", zoomStep),
+		widget.NewFormItem("Pan step", panStep),
+		widget.NewFormItem("Rotate step", rotateStep),
+		widget.NewFormItem("Window width", width),
+		widget.NewFormItem("Window height", height),
+		widget.NewFormItem("Output folder", outputDir),
+		widget.NewFormItem("File names", template),
+	)
+
+	keys := widget.NewForm()
+	keyEntries := map[string]*widget.Entry{}
+	for _, a := range actions {
+		e := widget.NewEntry()
+		e.SetText(s.Keys[a.name])
+		keyEntries[a.name] = e
+		keys.Append(a.name, e)
+	}
+	keys.Append("", widget.NewLabel("One character, or Up, Down, Left, Right,\n"+
+		"Home, End, PageUp, PageDown, Insert, Delete,\nBackSpace, Tab, Escape, Return, Enter or F1 to F12"))
+
+	tabs := container.NewAppTabs(
+		container.NewTabItem("View", view),
+		container.NewTabItem("Keys", container.NewVScroll(keys)))
+
+	f.unbindKeys()
+	d := dialog.NewCustomConfirm("Settings", "Save", "Cancel", tabs, func(ok bool) {
+		defer f.bindKeys()
+		if !ok {
+			return
+		}
+		var errs []string
+		float := func(name string, e *widget.Entry) float64 {
+			v, err := strconv.ParseFloat(strings.TrimSpace(e.Text), 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				errs = append(errs, fmt.Sprintf("%s %q is not a number", name, e.Text))
+			}
+			return v
+		}
+		n := Explorer{
+			X:          float("Start x", x),
+			Y:          float("Start y", y),
+			Scale:      float("Start scale", scale),
+			ZoomStep:   float("Zoom step", zoomStep),
+			PanStep:    float("Pan step", panStep),
+			RotateStep: float("Rotate step", rotateStep),
+			Width:      int(float("Window width", width)),
+			Height:     int(float("Window height", height)),
+			Keys:       map[string]string{},
+		}
+		if palette.Selected != themeColors {
+			n.Palette = palette.Selected
+		}
+		for name, e := range keyEntries {
+			n.Keys[name] = strings.TrimSpace(e.Text)
+		}
+		if n.Scale <= 0 || n.ZoomStep <= 1 || n.PanStep <= 0 || n.RotateStep <= 0 {
+			errs = append(errs, "the scale and steps must be positive, the zoom step above 1")
+		}
+		if err := checkKeys(n.Keys); err != nil {
+			errs = append(errs, err.Error())
+		}
+		if t := strings.TrimSpace(template.Text); t != "" {
+			if _, err := ExpandName(t, themeMandelData(f.view(), PX, PY)); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if len(errs) > 0 {
+			dialog.ShowError(fmt.Errorf("%s", strings.Join(errs, "\n")), f.window)
+			return
+		}
+
+		cfg.Explorer = n
+		cfg.OutputDir = strings.TrimSpace(outputDir.Text)
+		cfg.NameTemplate = strings.TrimSpace(template.Text)
+		err := SaveConfig(cfg)
+		if err != nil {
+			dialog.ShowError(err, f.window)
+			return
+		}
+		paletteChanged := n.Palette != f.settings.Palette
+		f.settings = n.WithDefaults()
+		f.setStart()
+		if paletteChanged {
+			f.palette, f.offset = f.startPalette, f.startOffset
+			f.redraw()
+		}
+		f.window.Resize(fyne.NewSize(float32(f.settings.Width), float32(f.settings.Height)))
+	}, f.window)
+	d.Resize(fyne.NewSize(480, 560))
+	d.Show()
+}
@@ -1,17 +1,11 @@
 package fractal
 
 import (
-	"encoding/json"
 	"fmt"
-	"image"
-	"image/jpeg"
-	"log"
-	"os"
-	"strconv"
+	"image/color"
 	"time"
 
-	exif "github.com/dsoprea/go-exif/v3"
-	jis "github.com/dsoprea/go-jpeg-image-structure/v2"
+	"fyne.io/fyne/v2/theme"
 )
 
 const (
@@ -19,124 +13,30 @@ const (
 	PY = 2160
 )
 
-func CreateJPG(f *Fractal) {
-
-	img := image.NewRGBA(image.Rect(0, 0, PX, PY))
-
-	for py := 0; py < PY; py++ {
-		for px := 0; px < PX; px++ {
-			c := f.mandelbrot(px, py, PX, PY)
-			img.Set(px, py, c)
-		}
-	}
-
-	fileName := "./pic/" + Time2str() + ".jpg"
-
-	err := SaveJPG(f, img, fileName)
-	if err != nil {
-		log.Fatalln("SaveJPG:", err)
-	}
-
-	err = AddMetadata(f, fileName)
-	if err != nil {
-		log.Fatalln("AddMetadata:", err)
-	}
-}
-
-func SaveJPG(f *Fractal, img *image.RGBA, fileName string) error {
-	// Create a new file
-	file, err := os.Create(fileName)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	// Encode the image as a JPEG and write it to the file
-	err = jpeg.Encode(file, img, nil)
-	if err != nil {
-		return err
+// themeMandelData describes a w by h image of v in manExplore's theme
+// colouring.
+func themeMandelData(v View, w, h int) *MandelData {
+	return &MandelData{
+		Version:    MandelDataVersion,
+		Scale:      v.Scale,
+		X:          v.X,
+		Y:          v.Y,
+		Rotation:   v.Rotation,
+		Iterations: v.Iterations,
+		Width:      w,
+		Height:     h,
+		Formula:    DefaultFormula,
+		Coloring:   ColoringTheme,
+		Samples:    max(v.Samples, 1),
+		Colors: []string{hexColor(theme.BackgroundColor()),
+			hexColor(theme.PrimaryColor()), hexColor(theme.ForegroundColor())},
 	}
-	return nil
-}
-
-type MandelData struct {
-	Author   string
-	FileName string
-	Scale    float64
-	X, Y     float64
 }
 
-func (m *MandelData) Encode() ([]byte, error) {
-	scale := json.Number(strconv.FormatFloat(m.Scale, 'g', -1, 64))
-	x := json.Number(strconv.FormatFloat(m.X, 'g', -1, 64))
-	y := json.Number(strconv.FormatFloat(m.Y, 'g', -1, 64))
-
-	return json.Marshal(&struct {
-		Author   string      `json:"Author"`
-		FileName string      `json:"FileName"`
-		Scale    json.Number `json:"Scale"`
-		X        json.Number `json:"X"`
-		Y        json.Number `json:"Y"`
-	}{
-		Author:   m.Author,
-		FileName: m.FileName,
-		Scale:    scale,
-		X:        x,
-		Y:        y,
-	})
-}
-
-func AddMetadata(f *Fractal, fileName string) error {
-
-	intfc, err := jis.NewJpegMediaParser().ParseFile(fileName)
-	if err != nil {
-		log.Fatal(err)
-	}
-	sl := intfc.(*jis.SegmentList)
-
-	ib, err := sl.ConstructExifBuilder()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	ifd0Ib, err := exif.GetOrCreateIbFromRootIb(ib, "IFD0")
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	mandel := &MandelData{"John S. Dey Jr.", fileName,
-		f.currScale, f.currX, f.currY}
-
-	b, err := json.Marshal(mandel)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	jsonData := string(b)
-	fmt.Println(jsonData)
-
-	err = ifd0Ib.SetStandardWithName("DocumentName", jsonData)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = ifd0Ib.SetStandardWithName("Artist", mandel.Author)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = sl.SetExif(ib)
-	file, err := os.OpenFile(fileName, os.O_RDWR, 0644)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer file.Close()
-
-	err = sl.Write(file)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return nil
+// hexColor formats a colour as #rrggbb.
+func hexColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
 }
 
 func Time2str() string {
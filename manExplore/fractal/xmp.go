@@ -0,0 +1,217 @@
+package fractal
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// XMPNamespace holds one property per MandelData field, so that asset
+// managers that index XMP can search the fractal parameters.
+const XMPNamespace = "http://jsdey.com/ns/fractal/1.0/"
+
+const (
+	rdfNamespace = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	dcNamespace  = "http://purl.org/dc/elements/1.1/"
+	xmpKeyword   = "XML:com.adobe.xmp" // iTXt keyword of XMP in a png
+)
+
+// xmpJPEGPrefix starts the APP1 segment that holds XMP in a jpeg.
+var xmpJPEGPrefix = []byte("http://ns.adobe.com/xap/1.0/\x00")
+
+// XMP returns an XMP packet with m in the fractal namespace and the
+// Artist and Copyright of cfg as dc:creator and dc:rights.
+func (m *MandelData) XMP(cfg *Config) []byte {
+	md := *m
+	md.Version = MandelDataVersion
+
+	var b bytes.Buffer
+	text := func(s string) {
+		xml.EscapeText(&b, []byte(s))
+	}
+
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString(" <rdf:RDF xmlns:rdf=\"" + rdfNamespace + "\">\n")
+	b.WriteString("  <rdf:Description rdf:about=\"\"\n")
+	b.WriteString("    xmlns:dc=\"" + dcNamespace + "\"\n")
+	b.WriteString("    xmlns:fractal=\"" + XMPNamespace + "\">\n")
+
+	if cfg.Artist != "" {
+		b.WriteString("   <dc:creator><rdf:Seq><rdf:li>")
+		text(cfg.Artist)
+		b.WriteString("</rdf:li></rdf:Seq></dc:creator>\n")
+	}
+	if cfg.Copyright != "" {
+		b.WriteString("   <dc:rights><rdf:Alt><rdf:li xml:lang=\"x-default\">")
+		text(cfg.Copyright)
+		b.WriteString("</rdf:li></rdf:Alt></dc:rights>\n")
+	}
+
+	v := reflect.ValueOf(md)
+	for i := 0; i < v.NumField(); i++ {
+		name, f := v.Type().Field(i).Name, v.Field(i)
+		switch f.Kind() {
+		case reflect.Slice:
+			if f.Len() == 0 {
+				continue
+			}
+			b.WriteString("   <fractal:" + name + "><rdf:Seq>")
+			for j := 0; j < f.Len(); j++ {
+				b.WriteString("<rdf:li>")
+				text(f.Index(j).String())
+				b.WriteString("</rdf:li>")
+			}
+			b.WriteString("</rdf:Seq></fractal:" + name + ">\n")
+		default:
+			b.WriteString("   <fractal:" + name + ">")
+			text(xmpValue(f))
+			b.WriteString("</fractal:" + name + ">\n")
+		}
+	}
+
+	b.WriteString("  </rdf:Description>\n")
+	b.WriteString(" </rdf:RDF>\n")
+	b.WriteString("</x:xmpmeta>\n")
+	b.WriteString("<?xpacket end=\"w\"?>")
+	return b.Bytes()
+}
+
+// xmpValue formats a field the way json would, without quotes.
+func xmpValue(f reflect.Value) string {
+	switch f.Kind() {
+	case reflect.Float64:
+		return strconv.FormatFloat(f.Float(), 'g', -1, 64)
+	case reflect.Int:
+		return strconv.FormatInt(f.Int(), 10)
+	}
+	return f.String()
+}
+
+// DecodeXMP reads the fractal properties from an XMP packet, written as
+// elements or as attributes of rdf:Description. ok is false when the
+// packet has none. The result is checked as strictly as DecodeMandelData
+// checks json.
+func DecodeXMP(b []byte) (m *MandelData, ok bool, err error) {
+	props := map[string]any{}
+	set := func(name, value string) error {
+		f, found := reflect.TypeOf(MandelData{}).FieldByName(name)
+		if !found {
+			// Left for the strict json decoder to report.
+			props[name] = value
+			return nil
+		}
+		value = strings.TrimSpace(value)
+		switch f.Type.Kind() {
+		case reflect.Float64:
+			x, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("DecodeXMP: fractal:%s: %w", name, err)
+			}
+			props[name] = x
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("DecodeXMP: fractal:%s: %w", name, err)
+			}
+			props[name] = n
+		default:
+			props[name] = value
+		}
+		return nil
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	var prop string    // fractal property being read
+	var items []string // rdf:li items of a list property
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("DecodeXMP: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == rdfNamespace && t.Name.Local == "Description":
+				for _, a := range t.Attr {
+					if a.Name.Space == XMPNamespace {
+						err = set(a.Name.Local, a.Value)
+						if err != nil {
+							return nil, false, err
+						}
+					}
+				}
+			case t.Name.Space == XMPNamespace:
+				prop, items = t.Name.Local, nil
+				text.Reset()
+			case prop != "" && t.Name.Local == "li":
+				text.Reset()
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			switch {
+			case prop == "":
+			case t.Name.Space == XMPNamespace:
+				if items != nil {
+					props[prop] = items
+				} else {
+					err = set(prop, text.String())
+					if err != nil {
+						return nil, false, err
+					}
+				}
+				prop = ""
+			case t.Name.Local == "li":
+				items = append(items, strings.TrimSpace(text.String()))
+			}
+		}
+	}
+	if len(props) == 0 {
+		return nil, false, nil
+	}
+
+	js, err := json.Marshal(props)
+	if err != nil {
+		return nil, false, err
+	}
+	m, err = DecodeMandelData(js)
+	if err != nil {
+		return nil, false, err
+	}
+	return m, true, nil
+}
+
+// SidecarName is the .xmp file kept next to an image.
+func SidecarName(fileName string) string {
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".xmp"
+}
+
+// WriteSidecar writes the XMP of m next to the image fileName.
+func WriteSidecar(fileName string, m *MandelData, cfg *Config) error {
+	return writeFile(SidecarName(fileName), m.XMP(cfg), true)
+}
+
+// readSidecar reads the fractal properties of the .xmp next to fileName,
+// ok is false when there is no sidecar or it has none.
+func readSidecar(fileName string) (*MandelData, bool, error) {
+	b, err := os.ReadFile(SidecarName(fileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return DecodeXMP(b)
+}
@@ -0,0 +1,95 @@
+package fractal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// xmpPacket wraps rdf:Description attributes and content in a packet.
+func xmpPacket(attrs, content string) string {
+	return `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="` + rdfNamespace + `">` +
+		`<rdf:Description rdf:about="" xmlns:fractal="` + XMPNamespace + `" ` + attrs + `>` +
+		content + `</rdf:Description></rdf:RDF></x:xmpmeta>`
+}
+
+func TestDecodeXMP(t *testing.T) {
+	smooth := NewMandelData(View{X: -1.25, Y: 0.02, Scale: 0.003, Rotation: 90,
+		Palette: "grey", Offset: 0.75, Samples: 3}, 1280, 720)
+	smooth.Author = "A & B <c>"
+	smooth.FileName = "smooth.png"
+	theme := NewMandelData(View{X: 0.3, Y: -0.5, Scale: 0.5}, PX, PY)
+	theme.Coloring, theme.Palette = ColoringTheme, ""
+	theme.Colors = []string{"#000000", "#ff0000", "#ffffff"}
+	cfg := &Config{Artist: "someone", Copyright: "© someone"}
+
+	upgraded := &MandelData{
+		Version:    MandelDataVersion,
+		Scale:      0.25,
+		X:          -0.5,
+		Y:          0.1,
+		Iterations: AutoIterations(0.25),
+		Width:      PX,
+		Height:     PY,
+		Formula:    DefaultFormula,
+		Coloring:   ColoringTheme,
+		Samples:    1,
+	}
+
+	tests := []struct {
+		name   string
+		xmp    string
+		want   *MandelData
+		wantOK bool
+		err    string // Part of the error, empty for none
+	}{
+		{name: "smooth", xmp: string(smooth.XMP(cfg)), want: smooth, wantOK: true},
+		{name: "theme with colors", xmp: string(theme.XMP(&Config{})), want: theme, wantOK: true},
+		{
+			name:   "attributes",
+			xmp:    xmpPacket(`fractal:Scale="0.25" fractal:X="-0.5" fractal:Y="0.1"`, ""),
+			want:   upgraded,
+			wantOK: true,
+		},
+		{
+			name:   "elements with spaces",
+			xmp:    xmpPacket("", "<fractal:Scale> 0.25 </fractal:Scale><fractal:X>-0.5</fractal:X><fractal:Y>0.1</fractal:Y>"),
+			want:   upgraded,
+			wantOK: true,
+		},
+		{name: "no fractal properties", xmp: xmpPacket(`xmlns:dc="`+dcNamespace+`"`, "<dc:title>x</dc:title>")},
+		{
+			name: "unknown property",
+			xmp:  xmpPacket(`fractal:Scale="0.25" fractal:X="0" fractal:Y="0" fractal:Zoom="2"`, ""),
+			err:  `unknown field "Zoom"`,
+		},
+		{
+			name: "not a number",
+			xmp:  xmpPacket(`fractal:Scale="small"`, ""),
+			err:  "fractal:Scale",
+		},
+		{
+			name: "not renderable",
+			xmp:  xmpPacket(`fractal:Scale="-1" fractal:X="0" fractal:Y="0"`, ""),
+			err:  "must be positive",
+		},
+		{name: "malformed", xmp: "<x:xmpmeta><rdf:RDF>", err: "DecodeXMP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, ok, err := DecodeXMP([]byte(tt.xmp))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one with %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantOK || !reflect.DeepEqual(m, tt.want) {
+				t.Errorf("got %v\n%+v\nwant %v\n%+v", ok, m, tt.wantOK, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"jsdey.com/fractal"
+	"jsdey.com/fractal/catalog"
+)
+
+// gallery is a window with the thumbnails of the catalog. Selecting one
+// shows its parameters, tags and notes, Open continues exploring from it.
+type gallery struct {
+	explorer fyne.Window
+	openFile func(fileName string) error
+	window   fyne.Window
+	entries  []*catalog.Entry
+	selected *catalog.Entry
+
+	search *widget.Entry
+	grid   *widget.GridWrap
+	info   *widget.Label
+	tags   *widget.Entry
+	notes  *widget.Entry
+}
+
+// current is the open gallery window, if any.
+var current *gallery
+
+// showGallery opens the gallery window, or brings it to the front. It is
+// fractal.ShowGallery.
+func showGallery(explorer fyne.Window, open func(fileName string) error) {
+	if current != nil {
+		current.window.RequestFocus()
+		return
+	}
+	g := &gallery{explorer: explorer, openFile: open, window: fyne.CurrentApp().NewWindow("Gallery")}
+	current = g
+	g.window.SetOnClosed(func() { current = nil })
+
+	g.search = widget.NewEntry()
+	g.search.SetPlaceHolder("tags, depth range like 4..8, text:words in notes")
+	g.search.OnSubmitted = func(string) { g.load() }
+
+	g.grid = widget.NewGridWrap(
+		func() int { return len(g.entries) },
+		func() fyne.CanvasObject {
+			img := canvas.NewImageFromImage(nil)
+			img.FillMode = canvas.ImageFillContain
+			img.SetMinSize(fyne.NewSize(catalog.ThumbSize, catalog.ThumbSize*9/16))
+			return container.NewBorder(nil, widget.NewLabel(""), nil, nil, img)
+		},
+		func(id widget.GridWrapItemID, o fyne.CanvasObject) {
+			e := g.entries[id]
+			c := o.(*fyne.Container)
+			img := c.Objects[0].(*canvas.Image)
+			img.Image, _ = e.Thumbnail()
+			img.Refresh()
+			c.Objects[1].(*widget.Label).SetText(fmt.Sprintf("%s  depth %.1f",
+				filepath.Base(e.Path), e.Depth()))
+		})
+	g.grid.OnSelected = func(id widget.GridWrapItemID) { g.show(g.entries[id]) }
+
+	g.info = widget.NewLabel("")
+	g.info.TextStyle.Monospace = true
+	g.tags = widget.NewEntry()
+	g.tags.SetPlaceHolder("tags")
+	g.notes = widget.NewMultiLineEntry()
+	g.notes.SetPlaceHolder("notes")
+	g.notes.SetMinRowsVisible(3)
+	buttons := container.NewHBox(
+		widget.NewButton("Open", g.open),
+		widget.NewButton("Save", g.save),
+		widget.NewButton("Remove", g.remove),
+		widget.NewButton("Scan output folder", g.scan))
+	details := container.NewVBox(g.info, g.tags, g.notes, buttons)
+
+	top := container.NewBorder(nil, nil, nil, widget.NewButton("Search", g.load), g.search)
+	g.window.SetContent(container.NewBorder(top, nil, nil, details, g.grid))
+	g.window.Resize(fyne.NewSize(1100, 700))
+	g.load()
+	g.window.Show()
+}
+
+// withCatalog runs fn on the opened catalog, errors are shown in the
+// window.
+func (g *gallery) withCatalog(fn func(c *catalog.Catalog) error) bool {
+	c, err := catalog.Open("")
+	if err == nil {
+		err = fn(c)
+		c.Close()
+	}
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return false
+	}
+	return true
+}
+
+// load fills the grid with the entries that match the search.
+func (g *gallery) load() {
+	q, err := catalog.ParseQuery(g.search.Text)
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	g.withCatalog(func(c *catalog.Catalog) (err error) {
+		g.entries, err = c.Find(q)
+		return err
+	})
+	g.grid.UnselectAll()
+	g.grid.Refresh()
+	g.show(nil)
+	g.window.SetTitle(fmt.Sprintf("Gallery - %d images", len(g.entries)))
+}
+
+// show puts the details of e beside the grid.
+func (g *gallery) show(e *catalog.Entry) {
+	g.selected = e
+	if e == nil {
+		g.info.SetText("")
+		g.tags.SetText("")
+		g.notes.SetText("")
+		return
+	}
+	g.info.SetText(e.Path + "\n" + e.Meta.String())
+	g.tags.SetText(strings.Join(e.Tags, " "))
+	g.notes.SetText(e.Notes)
+}
+
+func (g *gallery) open() {
+	if g.selected == nil {
+		return
+	}
+	err := g.openFile(g.selected.Path)
+	if err != nil {
+		dialog.ShowError(err, g.window)
+		return
+	}
+	g.explorer.RequestFocus()
+}
+
+func (g *gallery) save() {
+	e := g.selected
+	if e == nil {
+		return
+	}
+	g.withCatalog(func(c *catalog.Catalog) error {
+		err := c.SetTags(e.Path, catalog.SplitTags(g.tags.Text))
+		if err != nil {
+			return err
+		}
+		err = c.SetNotes(e.Path, g.notes.Text)
+		if err != nil {
+			return err
+		}
+		updated, err := c.Get(e.Path)
+		if err == nil {
+			*e = *updated
+		}
+		return err
+	})
+	g.show(e)
+}
+
+func (g *gallery) remove() {
+	e := g.selected
+	if e == nil {
+		return
+	}
+	dialog.ShowConfirm("Remove", "Remove "+filepath.Base(e.Path)+
+		" from the gallery? The image itself is kept.", func(ok bool) {
+		if ok && g.withCatalog(func(c *catalog.Catalog) error { return c.Remove(e.Path) }) {
+			g.load()
+		}
+	}, g.window)
+}
+
+// scan indexes the images in the output folder.
+func (g *gallery) scan() {
+	dir := fractal.DefaultOutputDir
+	if cfg, err := fractal.LoadConfig(); err == nil && cfg.OutputDir != "" {
+		dir = cfg.OutputDir
+	}
+	var added, removed int
+	ok := g.withCatalog(func(c *catalog.Catalog) (err error) {
+		added, removed, err = c.Scan(dir)
+		return err
+	})
+	if ok {
+		g.load()
+		dialog.ShowInformation("Scan "+dir,
+			fmt.Sprintf("%d images added, %d removed", added, removed), g.window)
+	}
+}
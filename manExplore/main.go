@@ -8,6 +8,7 @@ import (
 	"fyne.io/fyne/v2/container"
 
 	"jsdey.com/fractal"
+	"jsdey.com/fractal/catalog"
 )
 
 type appInfo struct {
@@ -21,6 +22,9 @@ var apps = []appInfo{
 }
 
 func main() {
+	fractal.ImageSaved = catalog.AddImage
+	fractal.ShowGallery = showGallery
+
 	a := app.New()
 
 	content := container.NewMax()
@@ -29,6 +33,5 @@ func main() {
 	content.Objects = []fyne.CanvasObject{apps[0].run(w)}
 
 	w.SetContent(content)
-	w.Resize(fyne.NewSize(480, 270))
 	w.ShowAndRun()
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"jsdey.com/fractal/catalog"
+)
+
+const usage = `manGallery keeps the catalog of saved images that manExplore's
+gallery (g) shows, with thumbnails, tags and notes.
+
+  manGallery scan dir...            index new and changed images, drop deleted ones
+  manGallery add file...            index images
+  manGallery find [query...]        list images, newest first
+  manGallery show file...           print an image's entry
+  manGallery tag file tag...        replace an image's tags
+  manGallery note file text...      replace an image's notes
+  manGallery remove file...         drop images from the catalog, keeping the files
+
+A query is made of tags, a depth range min..max (either side may be left
+out, depth is the number of decades zoomed in) and text:word to look in
+paths and notes, e.g.  manGallery find spiral 6..  or  find 2..4 text:seahorse
+
+Flags:
+`
+
+func main() {
+	db := flag.String("db", "", "catalog file (default mandel/catalog.db in the config directory)")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) < 1 || (args[0] != "find" && len(args) < 2) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c, err := catalog.Open(*db)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer c.Close()
+
+	switch cmd, rest := args[0], args[1:]; cmd {
+	case "scan":
+		err = scan(c, rest)
+	case "add":
+		err = add(c, rest)
+	case "find":
+		err = find(c, rest)
+	case "show":
+		err = show(c, rest)
+	case "tag":
+		err = c.SetTags(rest[0], rest[1:])
+	case "note":
+		err = c.SetNotes(rest[0], strings.Join(rest[1:], " "))
+	case "remove":
+		err = remove(c, rest)
+	default:
+		err = fmt.Errorf("main: unknown command %q, run manGallery -h for help", cmd)
+	}
+	if err != nil {
+		fmt.Println(err)
+		c.Close()
+		os.Exit(1)
+	}
+}
+
+func scan(c *catalog.Catalog, dirs []string) error {
+	for _, dir := range dirs {
+		added, removed, err := c.Scan(dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d added, %d removed\n", dir, added, removed)
+	}
+	return nil
+}
+
+func add(c *catalog.Catalog, files []string) error {
+	var errs []error
+	for _, file := range files {
+		_, err := c.Add(file)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// find prints one line per matching image: depth, size, tags and path.
+func find(c *catalog.Catalog, words []string) error {
+	q, err := catalog.ParseQuery(strings.Join(words, " "))
+	if err != nil {
+		return err
+	}
+	entries, err := c.Find(q)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		fmt.Printf("%6.2f  %5d x %-5d  %-24s  %s\n", e.Depth(), e.Meta.Width, e.Meta.Height,
+			strings.Join(e.Tags, ","), e.Path)
+	}
+	return nil
+}
+
+func show(c *catalog.Catalog, files []string) error {
+	for i, file := range files {
+		e, err := c.Get(file)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%-11s %s\n", "Path", e.Path)
+		fmt.Printf("%-11s %s\n", "Added", e.Added.Format("2006-01-02 15:04:05"))
+		fmt.Printf("%-11s %.2f\n", "Depth", e.Depth())
+		fmt.Printf("%-11s %s\n", "Tags", strings.Join(e.Tags, " "))
+		fmt.Printf("%-11s %s\n", "Notes", e.Notes)
+		fmt.Print(&e.Meta)
+	}
+	return nil
+}
+
+func remove(c *catalog.Catalog, files []string) error {
+	var errs []error
+	for _, file := range files {
+		errs = append(errs, c.Remove(file))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"jsdey.com/fractal"
+)
+
+const usage = `manMeta reads and edits the fractal metadata of images written by
+manExplore, manSinglePNG and manMovie.
+
+  manMeta show file...              print the parameters
+  manMeta json file...              print the parameters as json
+  manMeta set file name=value...    change fields, e.g. Palette=fire Scale=1e-5
+  manMeta strip file...             remove the fractal json, XMP and .xmp sidecar
+  manMeta copy from to...           copy the parameters of one image to others
+`
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usage) }
+	flag.Parse()
+	args := flag.Args()
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, files := args[0], args[1:]; cmd {
+	case "show":
+		err = show(files, false)
+	case "json":
+		err = show(files, true)
+	case "set":
+		err = set(files[0], files[1:])
+	case "strip":
+		err = strip(files)
+	case "copy":
+		err = copyMeta(files[0], files[1:])
+	default:
+		err = fmt.Errorf("main: unknown command %q, run manMeta -h for help", cmd)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// show prints the metadata of every file, as text separated by blank
+// lines or as one json object per file.
+func show(files []string, asJSON bool) error {
+	var errs []error
+	for i, file := range files {
+		md, err := fractal.ReadMetadata(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if asJSON {
+			b, err := json.MarshalIndent(md, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		if len(files) > 1 {
+			fmt.Println(file)
+		}
+		fmt.Print(md)
+	}
+	return errors.Join(errs...)
+}
+
+// set changes fields of the metadata and writes it back. The result must
+// still describe an image that can be rendered.
+func set(file string, assignments []string) error {
+	if len(assignments) == 0 {
+		return errors.New("set: give at least one name=value")
+	}
+	md, err := fractal.ReadMetadata(file)
+	if err != nil {
+		return err
+	}
+	for _, a := range assignments {
+		name, value, ok := strings.Cut(a, "=")
+		if !ok {
+			return fmt.Errorf("set: %q is not name=value", a)
+		}
+		err = md.Set(name, value)
+		if err != nil {
+			return err
+		}
+	}
+	err = md.Check()
+	if err != nil {
+		return fmt.Errorf("set: %w", err)
+	}
+	return write(file, md)
+}
+
+func strip(files []string) error {
+	var errs []error
+	for _, file := range files {
+		errs = append(errs, fractal.StripMetadata(file))
+	}
+	return errors.Join(errs...)
+}
+
+// copyMeta gives every file in to the parameters of from, with its own
+// file name.
+func copyMeta(from string, to []string) error {
+	if len(to) == 0 {
+		return errors.New("copy: give at least one file to copy to")
+	}
+	md, err := fractal.ReadMetadata(from)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, file := range to {
+		c := *md
+		c.FileName = filepath.Base(file)
+		errs = append(errs, write(file, &c))
+	}
+	return errors.Join(errs...)
+}
+
+// write stores md with the Artist, Copyright and sidecar setting of the
+// configuration.
+func write(file string, md *fractal.MandelData) error {
+	cfg, err := fractal.LoadConfig()
+	if err != nil {
+		return err
+	}
+	return fractal.WriteMetadata(file, md, cfg)
+}
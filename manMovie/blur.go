@@ -0,0 +1,40 @@
+package main
+
+import (
+	"image"
+
+	"jsdey.com/fractal"
+)
+
+// blurFrame draws frame n as the average of -blur sub-frames spread over
+// the part of the frame interval the shutter is open, centred on the
+// frame's time. Each sub-frame keeps the -ss supersampling of the view.
+func (m *Movie) blurFrame(tl Timeline, n int,
+	draw func(v fractal.View) (*image.RGBA, error)) (*image.RGBA, error) {
+
+	if m.Blur <= 1 {
+		return draw(m.frameView(tl, n))
+	}
+
+	t := float64(n) / float64(m.FPS)
+	open := m.Shutter / 360 / float64(m.FPS)
+	var sum []uint32
+	for s := 0; s < m.Blur; s++ {
+		img, err := draw(m.viewAt(tl, t+open*((float64(s)+0.5)/float64(m.Blur)-0.5)))
+		if err != nil {
+			return nil, err
+		}
+		if sum == nil {
+			sum = make([]uint32, len(img.Pix))
+		}
+		for i, p := range img.Pix {
+			sum[i] += uint32(p)
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, m.W, m.H))
+	for i := range img.Pix {
+		img.Pix[i] = uint8((sum[i] + uint32(m.Blur)/2) / uint32(m.Blur))
+	}
+	return img, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"jsdey.com/fractal"
+)
+
+// Encoder turns the frames of a movie, given in order, into a file.
+type Encoder interface {
+	WriteFrame(img *image.RGBA) error
+	// Close finishes the file. A file that could not be finished is
+	// removed.
+	Close() error
+	// Abort stops the encoder and removes the partial file.
+	Abort()
+}
+
+// newEncoder starts the encoder for the job's format.
+func (m *Movie) newEncoder(ctx context.Context, tl Timeline) (Encoder, error) {
+	switch m.Format {
+	case "gif":
+		return newGIFEncoder(m.Output, m.FPS, m.Dither), nil
+	case "apng":
+		return newAPNGEncoder(m.Output, m.Frames, m.FPS)
+	case "y4m":
+		return newY4MEncoder(m.Output, m.FPS)
+	case "png":
+		return newPNGSeqEncoder(m.Output, func(n int) *fractal.MandelData {
+			return fractal.NewMandelData(m.frameView(tl, n), m.W, m.H)
+		})
+	}
+	return newFFmpeg(ctx, m.Output, m.FPS, m.Codec)
+}
+
+// ffmpeg streams png frames to an ffmpeg process.
+type ffmpeg struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *tailBuffer
+	enc    *png.Encoder
+	output string
+	done   bool
+}
+
+// checkFFmpeg makes sure ffmpeg can be started, so that a missing binary
+// is reported before any frame is rendered.
+func checkFFmpeg() error {
+	_, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return fmt.Errorf("checkFFmpeg: ffmpeg is not installed or not on the PATH: %w", err)
+	}
+	return nil
+}
+
+// newFFmpeg starts ffmpeg writing output. Cancelling ctx kills ffmpeg.
+func newFFmpeg(ctx context.Context, output string, fps int, codec string) (*ffmpeg, error) {
+	rate := strconv.Itoa(fps)
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-y", "-hide_banner",
+		"-f", "image2pipe", "-framerate", rate, "-i", "pipe:0",
+		"-r", rate, "-pix_fmt", "yuv420p", "-vcodec", codec, output)
+
+	f := &ffmpeg{
+		cmd:    cmd,
+		stderr: &tailBuffer{max: 16 << 10},
+		// The pipe only needs to be read back by ffmpeg, so favour speed.
+		enc:    &png.Encoder{CompressionLevel: png.BestSpeed},
+		output: output,
+	}
+	cmd.Stderr = f.stderr
+
+	var err error
+	f.stdin, err = cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("newFFmpeg: %w", err)
+	}
+	return f, nil
+}
+
+func (f *ffmpeg) WriteFrame(img *image.RGBA) error {
+	err := f.enc.Encode(f.stdin, img)
+	if err != nil {
+		// A broken pipe means ffmpeg has quit, its exit says why.
+		werr := f.wait()
+		f.remove()
+		if werr != nil {
+			return werr
+		}
+		return fmt.Errorf("WriteFrame: %w", err)
+	}
+	return nil
+}
+
+func (f *ffmpeg) Close() error {
+	if f.done {
+		return nil
+	}
+	err := f.wait()
+	if err != nil {
+		f.remove()
+	}
+	return err
+}
+
+func (f *ffmpeg) Abort() {
+	if f.done {
+		return
+	}
+	f.cmd.Process.Kill()
+	f.wait()
+	f.remove()
+}
+
+// wait closes ffmpeg's input and waits for it to exit. A failure carries
+// the exit code and the end of ffmpeg's stderr.
+func (f *ffmpeg) wait() error {
+	f.done = true
+	f.stdin.Close()
+	err := f.cmd.Wait()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("ffmpeg exited with code %d:\n%s",
+			exitErr.ExitCode(), strings.TrimSpace(f.stderr.String()))
+	}
+	return err
+}
+
+func (f *ffmpeg) remove() {
+	err := os.Remove(f.output)
+	if err == nil {
+		fmt.Println("removed partial", f.output)
+	}
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	b   []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.b = append(t.b, p...)
+	if len(t.b) > t.max {
+		t.b = t.b[len(t.b)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.b)
+}
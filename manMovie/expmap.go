@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"slices"
+	"sync"
+
+	"jsdey.com/fractal"
+)
+
+// expStrip is a zoom path rendered once as an exponential map. Column a
+// looks along the angle a·step about the centre and row r lies at radius
+// rMin·e^(r·step), so the strip has square samples at every depth. Each
+// frame of the zoom is then resampled from it instead of being iterated.
+type expStrip struct {
+	centre complex128
+	cols   int
+	rows   int
+	logMin float64 // log of the radius of row 0
+	step   float64 // angle between columns and log radius between rows
+	img    *image.RGBA
+}
+
+// newExpStrip renders the strip covering every frame of tl. quality
+// oversamples the strip, 1 matches the frame's pixels at its corners.
+func (m *Movie) newExpStrip(tl Timeline, quality float64) (*expStrip, error) {
+	first := tl[0]
+	minScale, maxScale := first.Scale, first.Scale
+	iterations := first.Iterations
+	for _, k := range tl {
+		if k.X != first.X || k.Y != first.Y || k.Offset != first.Offset {
+			return nil, errors.New("newExpStrip: the exponential map needs one centre " +
+				"and palette offset for the whole movie")
+		}
+		minScale = math.Min(minScale, k.Scale)
+		maxScale = math.Max(maxScale, k.Scale)
+		if k.Iterations > iterations {
+			iterations = k.Iterations
+		}
+	}
+	// A schedule sets the iterations of each frame, the strip needs the
+	// deepest of them.
+	if len(m.iters) > 0 {
+		iterations = slices.Max(m.iters)
+	}
+	if quality <= 0 {
+		return nil, fmt.Errorf("newExpStrip: quality %g must be positive", quality)
+	}
+
+	shade, err := fractal.View{
+		Iterations: iterations,
+		Formula:    m.Formula,
+		Palette:    m.Palette,
+		Offset:     first.Offset,
+	}.Shader()
+	if err != nil {
+		return nil, err
+	}
+
+	// From half a pixel of the deepest frame out to the corners of the
+	// shallowest one.
+	w, h := float64(m.W), float64(m.H)
+	rMin := 3.5 * minScale / w / 2
+	rMax := 3.5 * maxScale / w * math.Hypot(w, h) / 2
+
+	e := &expStrip{
+		centre: complex(first.X, -first.Y),
+		cols:   int(math.Ceil(math.Pi * math.Hypot(w, h) * quality)),
+		logMin: math.Log(rMin),
+	}
+	e.step = 2 * math.Pi / float64(e.cols)
+	e.rows = int(math.Ceil(math.Log(rMax/rMin)/e.step)) + 2
+	e.img = image.NewRGBA(image.Rect(0, 0, e.cols, e.rows))
+	fmt.Printf("newExpStrip: rendering a %d x %d strip\n", e.cols, e.rows)
+
+	rows := make(chan int, e.rows)
+	for r := 0; r < e.rows; r++ {
+		rows <- r
+	}
+	close(rows)
+
+	var wg sync.WaitGroup
+	for n := 0; n < runtime.NumCPU(); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range rows {
+				radius := math.Exp(e.logMin + float64(r)*e.step)
+				for a := 0; a < e.cols; a++ {
+					sin, cos := math.Sincos(float64(a) * e.step)
+					c := e.centre + complex(radius*cos, radius*sin)
+					e.img.SetRGBA(a, r, shade(c))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return e, nil
+}
+
+// frame resamples the strip into a w by h image of v, which must share the
+// strip's centre.
+func (e *expStrip) frame(v fractal.View, w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			d := v.Point(float64(px), float64(py), w, h) - e.centre
+			logR := math.Log(math.Hypot(real(d), imag(d)))
+			theta := math.Atan2(imag(d), real(d))
+			if theta < 0 {
+				theta += 2 * math.Pi
+			}
+			img.SetRGBA(px, py, e.sample(theta/e.step, (logR-e.logMin)/e.step))
+		}
+	}
+	return img
+}
+
+// sample reads the strip bilinearly at column a and row r. Columns wrap
+// around the circle, rows are clamped.
+func (e *expStrip) sample(a, r float64) color.RGBA {
+	r = math.Max(0, math.Min(r, float64(e.rows-1)))
+	a0, r0 := math.Floor(a), math.Floor(r)
+	fa, fr := a-a0, r-r0
+
+	c0 := ((int(a0) % e.cols) + e.cols) % e.cols
+	c1 := (c0 + 1) % e.cols
+	r1 := int(r0) + 1
+	if r1 >= e.rows {
+		r1 = e.rows - 1
+	}
+
+	var out [3]float64
+	for i, wt := range [4]float64{(1 - fa) * (1 - fr), fa * (1 - fr), (1 - fa) * fr, fa * fr} {
+		col, row := c0, int(r0)
+		if i&1 != 0 {
+			col = c1
+		}
+		if i&2 != 0 {
+			row = r1
+		}
+		p := e.img.PixOffset(col, row)
+		for ch := 0; ch < 3; ch++ {
+			out[ch] += wt * float64(e.img.Pix[p+ch])
+		}
+	}
+	return color.RGBA{uint8(out[0] + 0.5), uint8(out[1] + 0.5), uint8(out[2] + 0.5), 0xff}
+}
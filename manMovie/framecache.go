@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// frameCache keeps every rendered frame in a directory, numbered from
+// 0001.png, so that an interrupted job can pick up where it stopped.
+// manifest.json records the parameters the frames were rendered with.
+type frameCache struct {
+	dir string
+}
+
+// manifest holds everything that changes the pixels of a frame.
+type manifest struct {
+	W, H     int
+	FPS      int
+	Frames   int
+	Samples  int
+	Schedule string
+	Curve    string
+	MaxIter  int
+	Blur     int
+	Shutter  float64
+	Formula  string
+	Palette  string
+	ExpMap   float64
+	Reuse    float64
+	ReuseErr float64
+	Cycle    float64
+	Timeline Timeline
+}
+
+const manifestName = "manifest.json"
+
+// openFrameCache prepares dir for the job. An existing cache is reused
+// only if it was made with the same parameters.
+func openFrameCache(dir string, m *Movie, tl Timeline) (*frameCache, error) {
+	want, err := json.MarshalIndent(&manifest{
+		W:        m.W,
+		H:        m.H,
+		FPS:      m.FPS,
+		Frames:   m.Frames,
+		Samples:  m.Samples,
+		Schedule: m.Schedule,
+		Curve:    m.Curve,
+		MaxIter:  m.MaxIter,
+		Blur:     m.Blur,
+		Shutter:  m.Shutter,
+		Formula:  m.Formula,
+		Palette:  m.Palette,
+		ExpMap:   m.ExpMap,
+		Reuse:    m.Reuse,
+		ReuseErr: m.ReuseErr,
+		Cycle:    m.Cycle,
+		Timeline: tl,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := filepath.Join(dir, manifestName)
+	have, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return &frameCache{dir}, writeFileAtomic(fileName, want)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(bytes.TrimSpace(have), bytes.TrimSpace(want)) {
+		return nil, fmt.Errorf("openFrameCache: %s was rendered with other parameters, "+
+			"remove it or pick another -cache directory", dir)
+	}
+	return &frameCache{dir}, nil
+}
+
+func (c *frameCache) path(frame int) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%04d.png", frame+1))
+}
+
+// Load returns a cached frame. A missing or unreadable frame is reported
+// as not cached, so it will simply be rendered again.
+func (c *frameCache) Load(frame int) (*image.RGBA, bool) {
+	file, err := os.Open(c.path(frame))
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	img, err := png.Decode(file)
+	if err != nil {
+		return nil, false
+	}
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba, true
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba, true
+}
+
+// Store writes a frame. The frame only appears under its final name once
+// it is complete.
+func (c *frameCache) Store(frame int, img *image.RGBA) error {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path(frame), buf.Bytes())
+}
+
+// writeFileAtomic writes through a temporary file in the same directory
+// and renames it into place.
+func writeFileAtomic(fileName string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"jsdey.com/fractal"
+)
+
+// Job holds everything needed to render a movie without asking anyone.
+// It can be read from a json file given with -job, flags set on the
+// command line win over the values in the file.
+type Job struct {
+	Input       string  // image written by manExplore or manSinglePNG
+	Output      string  // movie file, defaults to mov/<input or keys name>.mp4
+	Format      string  // ffmpeg, gif, apng, y4m or png, by default from Output
+	FPS         int     // frames per second
+	Duration    float64 // In seconds
+	W, H        int     // with and height in pixals
+	Iterations  int     // Max interations
+	Schedule    string  // fixed, auto, curve or adaptive iterations per frame
+	Curve       string  // scale:iterations pairs for the curve schedule
+	MaxIter     int     // Ceiling of the auto, curve and adaptive schedules
+	Samples     int     // Supersampling, each pixel averages Samples² points
+	Blur        int     // Sub-frames averaged into every frame, 0 or 1 is off
+	Shutter     float64 // Degrees of the frame interval the sub-frames span
+	Codec       string  // ffmpeg video codec
+	Dither      bool    // gif only
+	Formula     string
+	Palette     string
+	Workers     int        // frames rendered at the same time
+	Ahead       int        // frames rendered or waiting for the encoder
+	Cache       string     // directory keeping frames so a job can resume
+	ExpMap      float64    // exponential map oversampling, 0 renders every frame
+	Reuse       float64    // keyframe oversize for frame reuse, 0 renders every frame
+	ReuseErr    float64    // mean error that makes a reused frame render in full
+	Cycle       float64    // palette cycles per second, added to the keyframe offsets
+	Keys        string     // json keyframe file or comma separated images
+	Preview     int        // render every Nth frame into a contact sheet instead
+	PreviewSize float64    // size of the preview frames relative to W and H
+	Easing      string     // for keyframes that do not name one
+	Keyframes   []Keyframe // only settable in a job file
+}
+
+// formatExt is the file extension each output format gets by default. A
+// png sequence is a directory.
+var formatExt = map[string]string{
+	"":       ".mp4",
+	"ffmpeg": ".mp4",
+	"gif":    ".gif",
+	"apng":   ".png",
+	"y4m":    ".y4m",
+	"png":    "",
+}
+
+func defaultJob() Job {
+	return Job{
+		FPS:         30,
+		Duration:    20,
+		W:           960, // 3840
+		H:           560, // 2160
+		Iterations:  300,
+		Schedule:    "fixed",
+		MaxIter:     100000,
+		Samples:     1,
+		Shutter:     180,
+		Codec:       "libx264",
+		Dither:      true,
+		Formula:     fractal.DefaultFormula,
+		Palette:     fractal.DefaultPalette,
+		Easing:      "linear",
+		Workers:     runtime.NumCPU(),
+		ReuseErr:    10,
+		PreviewSize: 0.25,
+	}
+}
+
+// parseJob builds a Job from the defaults, the optional job file and the
+// command line, in that order.
+func parseJob(args []string) (Job, error) {
+	job := defaultJob()
+	fs, jobFile := jobFlags(&job)
+	err := fs.Parse(args)
+	if err != nil {
+		return job, err
+	}
+
+	if *jobFile != "" {
+		// Load the file over the defaults, then parse again so that the
+		// flags given on the command line replace the file's values.
+		job = defaultJob()
+		err = readJobFile(*jobFile, &job)
+		if err != nil {
+			return job, err
+		}
+		fs, _ = jobFlags(&job)
+		err = fs.Parse(args)
+		if err != nil {
+			return job, err
+		}
+	}
+
+	if job.Output == "" {
+		name := job.Input
+		if name == "" {
+			name = strings.Split(job.Keys, ",")[0]
+		}
+		name = filepath.Base(name)
+		job.Output = filepath.Join("mov", strings.TrimSuffix(name, filepath.Ext(name))+
+			formatExt[job.Format])
+	}
+	if job.Format == "" {
+		job.Format = "ffmpeg"
+		for format, ext := range formatExt {
+			if format != "" && ext != "" && strings.EqualFold(filepath.Ext(job.Output), ext) {
+				job.Format = format
+			}
+		}
+	}
+	return job, job.check()
+}
+
+// jobFlags defines the command line flags with the values in job as
+// defaults.
+func jobFlags(job *Job) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("manMovie", flag.ContinueOnError)
+	jobFile := fs.String("job", "", "json job file, flags override its values")
+	fs.StringVar(&job.Input, "in", job.Input, "jpeg or png written by manExplore or manSinglePNG")
+	fs.StringVar(&job.Output, "out", job.Output, "output movie, default mov/<input or keys name>.mp4")
+	fs.IntVar(&job.FPS, "fps", job.FPS, "frames per second")
+	fs.Float64Var(&job.Duration, "duration", job.Duration, "length of the movie in seconds")
+	fs.IntVar(&job.W, "w", job.W, "width in pixels")
+	fs.IntVar(&job.H, "h", job.H, "height in pixels")
+	fs.IntVar(&job.Iterations, "i", job.Iterations, "maximum iterations")
+	fs.StringVar(&job.Schedule, "schedule", job.Schedule,
+		"iterations per frame: fixed (-i), auto (as manExplore), curve (-curve) or "+
+			"adaptive (probe each part of the movie)")
+	fs.StringVar(&job.Curve, "curve", job.Curve,
+		"scale:iterations pairs for -schedule curve, e.g. 1:100,1e-4:1500,1e-8:6000")
+	fs.IntVar(&job.MaxIter, "max-iter", job.MaxIter,
+		"most iterations the auto, curve and adaptive schedules may pick")
+	fs.IntVar(&job.Samples, "ss", job.Samples, "supersampling, each pixel averages ss×ss points")
+	fs.IntVar(&job.Blur, "blur", job.Blur,
+		"motion blur, each frame averages this many sub-frames, 0 or 1 is off")
+	fs.Float64Var(&job.Shutter, "shutter", job.Shutter,
+		"shutter angle in degrees, 360 blurs over the whole time between frames")
+	fs.StringVar(&job.Format, "format", job.Format,
+		"ffmpeg, gif, apng, y4m or png (a directory of frames), by default from -out")
+	fs.StringVar(&job.Codec, "codec", job.Codec, "ffmpeg video codec")
+	fs.BoolVar(&job.Dither, "dither", job.Dither, "dither gif frames")
+	fs.StringVar(&job.Formula, "formula", job.Formula,
+		"one of "+strings.Join(fractal.FormulaNames(), ", "))
+	fs.StringVar(&job.Palette, "palette", job.Palette,
+		"one of "+strings.Join(fractal.PaletteNames(), ", "))
+	fs.IntVar(&job.Workers, "workers", job.Workers, "frames rendered at the same time")
+	fs.IntVar(&job.Ahead, "ahead", job.Ahead,
+		"frames held rendering or waiting for ffmpeg, 0 is twice -workers")
+	fs.Float64Var(&job.ExpMap, "expmap", job.ExpMap,
+		"render a zoom once as an exponential map with this oversampling (try 1 to 2), "+
+			"0 renders every frame")
+	fs.Float64Var(&job.Reuse, "reuse", job.Reuse,
+		"render keyframes this many times larger (try 2) and draw the frames "+
+			"between them from it, 0 renders every frame")
+	fs.Float64Var(&job.ReuseErr, "reuse-err", job.ReuseErr,
+		"mean colour error (0-255) above which a reused frame is rendered in full")
+	fs.Float64Var(&job.Cycle, "cycle", job.Cycle,
+		"palette cycles per second, a fixed view is iterated once and only recoloured")
+	fs.StringVar(&job.Cache, "cache", job.Cache,
+		"directory to keep frames in, rerunning the job reuses them")
+	fs.StringVar(&job.Keys, "keys", job.Keys,
+		"json keyframe file, or comma separated jpegs or pngs to fly through")
+	fs.IntVar(&job.Preview, "preview", job.Preview,
+		"dry run: render every nth frame small into a contact sheet, print the schedule "+
+			"and estimate the render time")
+	fs.Float64Var(&job.PreviewSize, "preview-size", job.PreviewSize,
+		"size of the preview frames relative to -w and -h")
+	fs.StringVar(&job.Easing, "easing", job.Easing,
+		"default keyframe easing, one of "+strings.Join(easingNames(), ", "))
+	return fs, jobFile
+}
+
+func readJobFile(fileName string, job *Job) error {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(b, job)
+	if err != nil {
+		return fmt.Errorf("readJobFile: %s: %w", fileName, err)
+	}
+	return nil
+}
+
+func (j *Job) check() error {
+	if j.Input == "" && j.Keys == "" && len(j.Keyframes) == 0 {
+		return errors.New("check: no input image or keyframes, use -in, -keys or a job file")
+	}
+	if j.FPS < 1 || j.Duration <= 0 {
+		return errors.New("check: fps and duration must be positive")
+	}
+	if j.Frames() < 1 {
+		return errors.New("check: duration × fps gives no frames")
+	}
+	if j.W < 1 || j.H < 1 || j.Iterations < 1 {
+		return errors.New("check: width, height and iterations must be positive")
+	}
+	if !slices.Contains(schedules, j.Schedule) {
+		return fmt.Errorf("check: unknown schedule %q, use one of %s",
+			j.Schedule, strings.Join(schedules, ", "))
+	}
+	if j.Schedule == "curve" {
+		if _, err := parseCurve(j.Curve); err != nil {
+			return err
+		}
+	}
+	if j.MaxIter < 1 {
+		return errors.New("check: max-iter must be positive")
+	}
+	if j.Samples < 1 || j.Blur < 0 {
+		return errors.New("check: ss must be positive and blur not negative")
+	}
+	if j.Shutter <= 0 || j.Shutter > 360 {
+		return errors.New("check: shutter must be above 0 and at most 360 degrees")
+	}
+	if _, ok := formatExt[j.Format]; !ok || j.Format == "" {
+		return fmt.Errorf("check: unknown format %q", j.Format)
+	}
+	if j.ExpMap < 0 {
+		return errors.New("check: expmap must not be negative")
+	}
+	if j.Reuse != 0 && j.Reuse < 1 {
+		return errors.New("check: reuse must be 0 or at least 1")
+	}
+	if j.Reuse != 0 && j.ExpMap != 0 {
+		return errors.New("check: pick one of expmap and reuse")
+	}
+	if j.Cycle != 0 && (j.ExpMap != 0 || j.Reuse != 0) {
+		return errors.New("check: cycle cannot be combined with expmap or reuse")
+	}
+	if (j.Blur > 1 || j.Samples > 1) && j.Reuse != 0 {
+		return errors.New("check: ss and blur cannot be combined with reuse")
+	}
+	if j.Preview < 0 || j.PreviewSize <= 0 || j.PreviewSize > 1 {
+		return errors.New("check: preview must not be negative and preview-size must be " +
+			"above 0 and at most 1")
+	}
+	if j.Workers < 1 || j.Ahead < 0 {
+		return errors.New("check: workers must be positive and ahead not negative")
+	}
+	if j.Ahead == 0 {
+		j.Ahead = 2 * j.Workers
+	}
+	if _, err := fractal.LookupFormula(j.Formula); err != nil {
+		return err
+	}
+	if _, err := fractal.LookupPalette(j.Palette); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Frames is the number of frames in the movie.
+func (j *Job) Frames() int {
+	return int(j.Duration * float64(j.FPS))
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"jsdey.com/fractal"
+)
+
+// Keyframe pins the camera at a moment of the movie. Frames between two
+// keyframes are interpolated, the scale in log space so that the zoom
+// speed looks constant.
+type Keyframe struct {
+	Time       float64 // Seconds from the start of the movie
+	X, Y       float64
+	Scale      float64
+	Rotation   float64 // Degrees
+	Iterations int     // 0 keeps the job's iteration count
+	Offset     float64 // Palette offset, 1 is a full cycle
+	Easing     string  // Easing towards the next keyframe
+}
+
+// Timeline is a list of keyframes sorted by time.
+type Timeline []Keyframe
+
+// easings map the fraction of a segment that has passed to the fraction
+// of the change that has been applied.
+var easings = map[string]func(t float64) float64{
+	"linear": func(t float64) float64 { return t },
+	"in":     func(t float64) float64 { return t * t * t },
+	"out": func(t float64) float64 {
+		t = 1 - t
+		return 1 - t*t*t
+	},
+	"inout": func(t float64) float64 {
+		if t < 0.5 {
+			return 4 * t * t * t
+		}
+		t = -2*t + 2
+		return 1 - t*t*t/2
+	},
+	"sine": func(t float64) float64 { return (1 - math.Cos(t*math.Pi)) / 2 },
+}
+
+func easingNames() []string {
+	names := make([]string, 0, len(easings))
+	for name := range easings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// check sorts the timeline and fills empty easings and iteration counts
+// from the job.
+func (tl Timeline) check(easing string, iterations int) error {
+	if len(tl) == 0 {
+		return errors.New("check: the timeline has no keyframes")
+	}
+	sort.SliceStable(tl, func(i, j int) bool { return tl[i].Time < tl[j].Time })
+	for i := range tl {
+		if tl[i].Scale <= 0 {
+			return fmt.Errorf("check: keyframe %d has scale %g", i, tl[i].Scale)
+		}
+		if tl[i].Iterations <= 0 {
+			tl[i].Iterations = iterations
+		}
+		if tl[i].Easing == "" {
+			tl[i].Easing = easing
+		}
+		if _, ok := easings[tl[i].Easing]; !ok {
+			return fmt.Errorf("check: keyframe %d has unknown easing %q, use one of %s",
+				i, tl[i].Easing, strings.Join(easingNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// At returns the camera at time t. Times outside the timeline hold the
+// first or last keyframe.
+func (tl Timeline) At(t float64) Keyframe {
+	if t <= tl[0].Time {
+		return tl[0]
+	}
+	last := tl[len(tl)-1]
+	if t >= last.Time {
+		return last
+	}
+
+	i := sort.Search(len(tl), func(i int) bool { return tl[i].Time > t }) - 1
+	a, b := tl[i], tl[i+1]
+	f := easings[a.Easing]((t - a.Time) / (b.Time - a.Time))
+
+	lerp := func(x, y float64) float64 { return x + f*(y-x) }
+	return Keyframe{
+		Time:       t,
+		X:          lerp(a.X, b.X),
+		Y:          lerp(a.Y, b.Y),
+		Scale:      math.Exp(lerp(math.Log(a.Scale), math.Log(b.Scale))),
+		Rotation:   lerp(a.Rotation, b.Rotation),
+		Iterations: int(math.Round(lerp(float64(a.Iterations), float64(b.Iterations)))),
+		Offset:     lerp(a.Offset, b.Offset),
+		Easing:     a.Easing,
+	}
+}
+
+// fixed reports whether the camera stands still for the whole timeline, so
+// that only the palette offset can change between frames.
+func (tl Timeline) fixed() bool {
+	for _, k := range tl[1:] {
+		if k.X != tl[0].X || k.Y != tl[0].Y || k.Scale != tl[0].Scale ||
+			k.Rotation != tl[0].Rotation || k.Iterations != tl[0].Iterations {
+			return false
+		}
+	}
+	return true
+}
+
+// zoomTimeline is the original manMovie path: a straight zoom from scale 1
+// to the saved scale at the saved centre.
+func zoomTimeline(md *fractal.MandelData, duration float64) Timeline {
+	return Timeline{
+		{Time: 0, X: md.X, Y: md.Y, Scale: 1, Rotation: md.Rotation},
+		{Time: duration, X: md.X, Y: md.Y, Scale: md.Scale, Rotation: md.Rotation},
+	}
+}
+
+// loadKeyframes reads a json array of keyframes.
+func loadKeyframes(fileName string) (Timeline, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var tl Timeline
+	err = json.Unmarshal(b, &tl)
+	if err != nil {
+		return nil, fmt.Errorf("loadKeyframes: %s: %w", fileName, err)
+	}
+	return tl, nil
+}
+
+// keyframesFromImages makes one keyframe per image written by our tools,
+// evenly spread over the movie.
+func keyframesFromImages(files []string, duration float64) (Timeline, error) {
+	tl := make(Timeline, len(files))
+	for i, file := range files {
+		md, err := fractal.ReadMetadata(file)
+		if err != nil {
+			return nil, err
+		}
+		tl[i] = Keyframe{X: md.X, Y: md.Y, Scale: md.Scale, Rotation: md.Rotation}
+		if len(files) > 1 {
+			tl[i].Time = duration * float64(i) / float64(len(files)-1)
+		}
+	}
+	return tl, nil
+}
+
+// timeline picks the camera path for a job: keyframes in the job file,
+// then the -keys file or images, then a zoom into the input image.
+func (j *Job) timeline() (Timeline, error) {
+	var tl Timeline
+	var err error
+
+	switch {
+	case len(j.Keyframes) > 0:
+		tl = append(tl, j.Keyframes...)
+	case strings.EqualFold(filepath.Ext(j.Keys), ".json"):
+		tl, err = loadKeyframes(j.Keys)
+	case j.Keys != "":
+		tl, err = keyframesFromImages(strings.Split(j.Keys, ","), j.Duration)
+	default:
+		var md *fractal.MandelData
+		md, err = fractal.ReadMetadata(j.Input)
+		if err != nil {
+			return nil, err
+		}
+		tl = zoomTimeline(md, j.Duration)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tl, tl.check(j.Easing, j.Iterations)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// near reports whether a and b agree to about nine digits.
+func near(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+func TestEasings(t *testing.T) {
+	halfway := map[string]float64{
+		"linear": 0.5,
+		"in":     0.125,
+		"out":    0.875,
+		"inout":  0.5,
+		"sine":   0.5,
+	}
+	if len(halfway) != len(easings) {
+		t.Fatalf("testing %d easings, there are %d", len(halfway), len(easings))
+	}
+	for name, want := range halfway {
+		t.Run(name, func(t *testing.T) {
+			ease := easings[name]
+			if ease(0) != 0 || !near(ease(1), 1) {
+				t.Errorf("runs from %g to %g, want 0 to 1", ease(0), ease(1))
+			}
+			if got := ease(0.5); !near(got, want) {
+				t.Errorf("halfway at %g, want %g", got, want)
+			}
+			for x := 0.0; x < 1; x += 0.01 {
+				if ease(x+0.01) < ease(x) {
+					t.Fatalf("goes back between %g and %g", x, x+0.01)
+				}
+			}
+		})
+	}
+}
+
+func TestTimelineAt(t *testing.T) {
+	tl := Timeline{
+		{Time: 0, X: 0, Y: 0, Scale: 1, Iterations: 100, Easing: "linear"},
+		{Time: 2, X: 1, Y: -1, Scale: 1e-4, Rotation: 90, Iterations: 300, Offset: 1, Easing: "in"},
+		{Time: 4, X: 1, Y: -1, Scale: 1e-6, Rotation: 90, Iterations: 300, Offset: 1, Easing: "linear"},
+	}
+
+	tests := []struct {
+		name string
+		t    float64
+		want Keyframe
+	}{
+		{"before the start", -1, tl[0]},
+		{"at the start", 0, tl[0]},
+		{"halfway, linear", 1, Keyframe{Time: 1, X: 0.5, Y: -0.5, Scale: 1e-2, Rotation: 45,
+			Iterations: 200, Offset: 0.5, Easing: "linear"}},
+		{"a quarter, linear", 0.5, Keyframe{Time: 0.5, X: 0.25, Y: -0.25, Scale: 1e-1, Rotation: 22.5,
+			Iterations: 150, Offset: 0.25, Easing: "linear"}},
+		{"at a keyframe", 2, tl[1]},
+		{"halfway, eased in", 3, Keyframe{Time: 3, X: 1, Y: -1, Scale: math.Pow(10, -4-2*0.125),
+			Rotation: 90, Iterations: 300, Offset: 1, Easing: "in"}},
+		{"at the end", 4, tl[2]},
+		{"after the end", 10, tl[2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := tl.At(tt.t)
+			w := tt.want
+			if !near(k.Time, w.Time) || !near(k.X, w.X) || !near(k.Y, w.Y) || !near(k.Scale, w.Scale) ||
+				!near(k.Rotation, w.Rotation) || k.Iterations != w.Iterations || !near(k.Offset, w.Offset) ||
+				k.Easing != w.Easing {
+				t.Errorf("At(%g) = %+v, want %+v", tt.t, k, w)
+			}
+		})
+	}
+}
+
+func TestTimelineCheck(t *testing.T) {
+	tl := Timeline{
+		{Time: 5, Scale: 0.01},
+		{Time: 0, Scale: 1, Iterations: 50, Easing: "sine"},
+	}
+	err := tl.check("inout", 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tl[0].Time != 0 || tl[1].Time != 5 {
+		t.Errorf("keyframes at %g and %g, want them sorted", tl[0].Time, tl[1].Time)
+	}
+	if tl[0].Iterations != 50 || tl[0].Easing != "sine" {
+		t.Errorf("the first keyframe became %+v, its own values must stay", tl[0])
+	}
+	if tl[1].Iterations != 400 || tl[1].Easing != "inout" {
+		t.Errorf("the second keyframe became %+v, want the job's iterations and easing", tl[1])
+	}
+
+	tests := []struct {
+		name string
+		tl   Timeline
+		err  string
+	}{
+		{"empty", Timeline{}, "no keyframes"},
+		{"zero scale", Timeline{{Scale: 1}, {Time: 1, Scale: 0}}, "scale 0"},
+		{"unknown easing", Timeline{{Scale: 1, Easing: "bounce"}}, `unknown easing "bounce"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tl.check("linear", 100)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want one with %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+
+	"jsdey.com/fractal"
+)
+
+// The encoders in this file need no ffmpeg. They are meant for short
+// clips and previews.
+
+// gifEncoder collects dithered, paletted frames and writes an animated
+// gif when closed. gif only has 256 colours, so every frame gets its own
+// palette.
+type gifEncoder struct {
+	output string
+	fps    int
+	dither bool
+	anim   gif.GIF
+}
+
+func newGIFEncoder(output string, fps int, dither bool) *gifEncoder {
+	return &gifEncoder{output: output, fps: fps, dither: dither}
+}
+
+func (g *gifEncoder) WriteFrame(img *image.RGBA) error {
+	p := image.NewPaletted(img.Bounds(), quantize(img, 256))
+	if g.dither {
+		draw.FloydSteinberg.Draw(p, p.Bounds(), img, img.Bounds().Min)
+	} else {
+		draw.Draw(p, p.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+
+	// Delays are in 1/100 s, spread the rounding over the frames.
+	n := len(g.anim.Image)
+	delay := (n+1)*100/g.fps - n*100/g.fps
+
+	g.anim.Image = append(g.anim.Image, p)
+	g.anim.Delay = append(g.anim.Delay, delay)
+	return nil
+}
+
+func (g *gifEncoder) Close() error {
+	file, err := os.Create(g.output)
+	if err != nil {
+		return err
+	}
+	err = gif.EncodeAll(file, &g.anim)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(g.output)
+	}
+	return err
+}
+
+func (g *gifEncoder) Abort() {
+	g.anim.Image = nil
+}
+
+// apngEncoder streams an animated png. Each frame is encoded by image/png
+// and its IDAT data is moved into fcTL and fdAT chunks.
+type apngEncoder struct {
+	file   *os.File
+	w      *bufio.Writer
+	output string
+	frames int
+	fps    int
+	seq    uint32
+	ihdr   []byte
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+func newAPNGEncoder(output string, frames, fps int) (*apngEncoder, error) {
+	if fps > 0xffff {
+		return nil, errors.New("newAPNGEncoder: fps must fit in 16 bits")
+	}
+	file, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+	return &apngEncoder{file: file, w: bufio.NewWriter(file), output: output,
+		frames: frames, fps: fps}, nil
+}
+
+func (a *apngEncoder) WriteFrame(img *image.RGBA) error {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return err
+	}
+	chunks, err := readChunks(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	first := a.ihdr == nil
+	var data []byte
+	for _, c := range chunks {
+		switch c.kind {
+		case "IHDR":
+			if first {
+				a.ihdr = c.data
+			} else if !bytes.Equal(a.ihdr, c.data) {
+				return errors.New("WriteFrame: frames must share size and colour type")
+			}
+		case "IDAT":
+			data = append(data, c.data...)
+		}
+	}
+
+	if first {
+		a.w.Write(pngSignature)
+		writeChunk(a.w, "IHDR", a.ihdr)
+		actl := make([]byte, 8)
+		binary.BigEndian.PutUint32(actl[0:], uint32(a.frames))
+		binary.BigEndian.PutUint32(actl[4:], 0) // Loop forever
+		writeChunk(a.w, "acTL", actl)
+	}
+
+	b := img.Bounds()
+	fctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(fctl[0:], a.seq)
+	binary.BigEndian.PutUint32(fctl[4:], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(b.Dy()))
+	binary.BigEndian.PutUint16(fctl[20:], 1)
+	binary.BigEndian.PutUint16(fctl[22:], uint16(a.fps))
+	a.seq++
+	writeChunk(a.w, "fcTL", fctl)
+
+	// The first frame doubles as the still image older viewers show.
+	if first {
+		return writeChunk(a.w, "IDAT", data)
+	}
+	fdat := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(fdat, a.seq)
+	a.seq++
+	return writeChunk(a.w, "fdAT", append(fdat, data...))
+}
+
+func (a *apngEncoder) Close() error {
+	writeChunk(a.w, "IEND", nil)
+	err := a.w.Flush()
+	if cerr := a.file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(a.output)
+	}
+	return err
+}
+
+func (a *apngEncoder) Abort() {
+	a.file.Close()
+	os.Remove(a.output)
+}
+
+type chunk struct {
+	kind string
+	data []byte
+}
+
+// readChunks splits a png file into its chunks.
+func readChunks(b []byte) ([]chunk, error) {
+	if !bytes.HasPrefix(b, pngSignature) {
+		return nil, errors.New("readChunks: not a png")
+	}
+	b = b[len(pngSignature):]
+
+	var chunks []chunk
+	for len(b) >= 12 {
+		n := int(binary.BigEndian.Uint32(b))
+		if n < 0 || 12+n > len(b) {
+			return nil, errors.New("readChunks: truncated chunk")
+		}
+		chunks = append(chunks, chunk{string(b[4:8]), b[8 : 8+n]})
+		b = b[12+n:]
+	}
+	return chunks, nil
+}
+
+// writeChunk writes one png chunk with its length and crc.
+func writeChunk(w io.Writer, kind string, data []byte) error {
+	head := make([]byte, 8)
+	binary.BigEndian.PutUint32(head, uint32(len(data)))
+	copy(head[4:], kind)
+
+	crc := crc32.NewIEEE()
+	crc.Write(head[4:])
+	crc.Write(data)
+	tail := binary.BigEndian.AppendUint32(nil, crc.Sum32())
+
+	for _, b := range [][]byte{head, data, tail} {
+		_, err := w.Write(b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// y4mEncoder writes raw 4:2:0 video in the YUV4MPEG2 format, which most
+// players and every encoder can read.
+type y4mEncoder struct {
+	file   *os.File
+	w      *bufio.Writer
+	output string
+	fps    int
+	header bool
+}
+
+func newY4MEncoder(output string, fps int) (*y4mEncoder, error) {
+	file, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+	return &y4mEncoder{file: file, w: bufio.NewWriter(file), output: output, fps: fps}, nil
+}
+
+func (y *y4mEncoder) WriteFrame(img *image.RGBA) error {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if !y.header {
+		fmt.Fprintf(y.w, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C420jpeg\n", w, h, y.fps)
+		y.header = true
+	}
+
+	cw, ch := (w+1)/2, (h+1)/2
+	lum := make([]byte, w*h)
+	cb := make([]int, cw*ch)
+	cr := make([]int, cw*ch)
+	count := make([]int, cw*ch)
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			i := img.PixOffset(b.Min.X+px, b.Min.Y+py)
+			Y, Cb, Cr := color.RGBToYCbCr(img.Pix[i], img.Pix[i+1], img.Pix[i+2])
+			lum[py*w+px] = Y
+			c := (py/2)*cw + px/2
+			cb[c] += int(Cb)
+			cr[c] += int(Cr)
+			count[c]++
+		}
+	}
+
+	y.w.WriteString("FRAME\n")
+	y.w.Write(lum)
+	plane := make([]byte, cw*ch)
+	for i := range plane {
+		plane[i] = uint8(cb[i] / count[i])
+	}
+	y.w.Write(plane)
+	for i := range plane {
+		plane[i] = uint8(cr[i] / count[i])
+	}
+	_, err := y.w.Write(plane)
+	return err
+}
+
+func (y *y4mEncoder) Close() error {
+	err := y.w.Flush()
+	if cerr := y.file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(y.output)
+	}
+	return err
+}
+
+func (y *y4mEncoder) Abort() {
+	y.file.Close()
+	os.Remove(y.output)
+}
+
+// pngSeqEncoder writes numbered png files into a directory. Each frame
+// carries its view, so any of them can be opened in manExplore or used
+// as a keyframe.
+type pngSeqEncoder struct {
+	dir  string
+	n    int
+	meta func(n int) *fractal.MandelData
+	cfg  *fractal.Config
+}
+
+func newPNGSeqEncoder(dir string, meta func(n int) *fractal.MandelData) (*pngSeqEncoder, error) {
+	cfg, err := fractal.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &pngSeqEncoder{dir: dir, meta: meta, cfg: cfg}, os.MkdirAll(dir, 0755)
+}
+
+func (p *pngSeqEncoder) WriteFrame(img *image.RGBA) error {
+	p.n++
+	name := fmt.Sprintf("%04d.png", p.n)
+	fileName := filepath.Join(p.dir, name)
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	md := p.meta(p.n - 1)
+	md.Author = p.cfg.Artist
+	md.FileName = name
+	err = fractal.EncodePNG(file, img, md, p.cfg)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && p.cfg.Sidecar {
+		err = fractal.WriteSidecar(fileName, md, p.cfg)
+	}
+	return err
+}
+
+func (p *pngSeqEncoder) Close() error {
+	return nil
+}
+
+// Abort keeps the frames written so far, they are complete images.
+func (p *pngSeqEncoder) Abort() {}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// filled is a w by h image of one colour.
+func filled(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func TestAPNGEncoder(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "clip.png")
+	frames := []*image.RGBA{
+		filled(5, 4, color.RGBA{0xff, 0, 0, 0xff}),
+		filled(5, 4, color.RGBA{0, 0xff, 0, 0xff}),
+		filled(5, 4, color.RGBA{0, 0, 0xff, 0xff}),
+	}
+	a, err := newAPNGEncoder(output, len(frames), 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, img := range frames {
+		if err := a.WriteFrame(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := readChunks(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	var seq []uint32
+	for _, c := range chunks {
+		kinds = append(kinds, c.kind)
+		switch c.kind {
+		case "acTL":
+			if n := binary.BigEndian.Uint32(c.data); n != 3 {
+				t.Errorf("acTL gives %d frames, want 3", n)
+			}
+		case "fcTL":
+			seq = append(seq, binary.BigEndian.Uint32(c.data))
+			w, h := binary.BigEndian.Uint32(c.data[4:]), binary.BigEndian.Uint32(c.data[8:])
+			num, den := binary.BigEndian.Uint16(c.data[20:]), binary.BigEndian.Uint16(c.data[22:])
+			if w != 5 || h != 4 || num != 1 || den != 24 {
+				t.Errorf("fcTL for %dx%d at %d/%d s, want 5x4 at 1/24 s", w, h, num, den)
+			}
+		case "fdAT":
+			seq = append(seq, binary.BigEndian.Uint32(c.data))
+		}
+	}
+	want := []string{"IHDR", "acTL", "fcTL", "IDAT", "fcTL", "fdAT", "fcTL", "fdAT", "IEND"}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("chunks %q, want %q", kinds, want)
+	}
+	if !reflect.DeepEqual(seq, []uint32{0, 1, 2, 3, 4}) {
+		t.Errorf("sequence numbers %v, want 0 to 4", seq)
+	}
+
+	// Viewers without apng support show the first frame.
+	still, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := still.At(2, 2).RGBA(); r != 0xffff || g != 0 || b != 0 {
+		t.Errorf("the still image is %v, want the first frame", still.At(2, 2))
+	}
+}
+
+func TestAPNGEncoderSize(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "clip.png")
+	a, err := newAPNGEncoder(output, 2, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.WriteFrame(filled(5, 4, color.RGBA{A: 0xff})); err != nil {
+		t.Fatal(err)
+	}
+	err = a.WriteFrame(filled(4, 5, color.RGBA{A: 0xff}))
+	if err == nil || !strings.Contains(err.Error(), "share size") {
+		t.Errorf("error %v, want one for the changed size", err)
+	}
+	a.Abort()
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("Abort left %s behind", output)
+	}
+
+	if _, err := newAPNGEncoder(output, 2, 1<<16); err == nil {
+		t.Error("an fps over 16 bits was accepted")
+	}
+}
+
+func TestY4MEncoder(t *testing.T) {
+	tests := []struct {
+		w, h       int
+		c          color.RGBA
+		y, cb, cr  uint8
+		chromaSize int
+	}{
+		{4, 2, color.RGBA{0x80, 0x80, 0x80, 0xff}, 0x80, 0x80, 0x80, 2},
+		{3, 3, color.RGBA{0, 0, 0, 0xff}, 0, 0x80, 0x80, 4},
+		{1, 1, color.RGBA{0xff, 0xff, 0xff, 0xff}, 0xff, 0x80, 0x80, 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%dx%d", tt.w, tt.h), func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "clip.y4m")
+			y, err := newY4MEncoder(output, 25)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				if err := y.WriteFrame(filled(tt.w, tt.h, tt.c)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := y.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := fmt.Sprintf("YUV4MPEG2 W%d H%d F25:1 Ip A1:1 C420jpeg\n", tt.w, tt.h)
+			if !bytes.HasPrefix(b, []byte(header)) {
+				t.Fatalf("file starts %q, want %q", b[:min(len(b), len(header))], header)
+			}
+			frame := []byte("FRAME\n")
+			frame = append(frame, bytes.Repeat([]byte{tt.y}, tt.w*tt.h)...)
+			frame = append(frame, bytes.Repeat([]byte{tt.cb}, tt.chromaSize)...)
+			frame = append(frame, bytes.Repeat([]byte{tt.cr}, tt.chromaSize)...)
+			want := append([]byte(header), bytes.Repeat(frame, 2)...)
+			if !bytes.Equal(b, want) {
+				t.Errorf("file is\n%q\nwant\n%q", b, want)
+			}
+		})
+	}
+}
+
+func TestGIFEncoder(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "clip.gif")
+	g := newGIFEncoder(output, 30, true)
+	img := filled(6, 6, color.RGBA{0x10, 0x20, 0x30, 0xff})
+	for i := 0; i < 3; i++ {
+		if err := g.WriteFrame(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	anim, err := gif.DecodeAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 30 fps does not divide 100, the delays make up 10/100 s together.
+	if !reflect.DeepEqual(anim.Delay, []int{3, 3, 4}) {
+		t.Errorf("delays %v, want [3 3 4]", anim.Delay)
+	}
+	if r, g, b, _ := anim.Image[0].At(3, 3).RGBA(); r>>8 != 0x10 || g>>8 != 0x20 || b>>8 != 0x30 {
+		t.Errorf("pixel %v, want #102030", anim.Image[0].At(3, 3))
+	}
+}
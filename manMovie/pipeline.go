@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"image"
+	"sync"
+)
+
+// rendered is a finished frame on its way to the encoder.
+type rendered struct {
+	n   int
+	img *image.RGBA
+	err error
+}
+
+// renderFrames renders frames 0 to count-1 on workers goroutines and
+// hands them to deliver strictly in frame order. No more than ahead frames
+// are rendering or waiting for their turn at any time, so a slow encoder
+// holds back the workers instead of filling memory. Cancelling ctx stops
+// the run after the frames being rendered are finished.
+func renderFrames(ctx context.Context, count, workers, ahead int,
+	render func(n int) (*image.RGBA, error),
+	deliver func(n int, img *image.RGBA) error) error {
+
+	if workers < 1 {
+		workers = 1
+	}
+	if ahead < workers {
+		ahead = workers
+	}
+
+	tokens := make(chan struct{}, ahead)
+	jobs := make(chan int)
+	results := make(chan rendered, ahead)
+	done := make(chan struct{})
+
+	// Hand out frame numbers, each one holding a token until delivered.
+	go func() {
+		defer close(jobs)
+		for n := 0; n < count; n++ {
+			select {
+			case tokens <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- n:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range jobs {
+				img, err := render(n)
+				results <- rendered{n, img, err}
+			}
+		}()
+	}
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	// The reorder buffer. It never holds more than ahead frames because
+	// every frame in it still holds a token.
+	pending := make(map[int]rendered, ahead)
+	for next := 0; next < count; {
+		var r rendered
+		select {
+		case r = <-results:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if r.err != nil {
+			return r.err
+		}
+		pending[r.n] = r
+
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+
+			err := deliver(r.n, r.img)
+			if err != nil {
+				return err
+			}
+			<-tokens
+			next++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// numbered is a 1 by 1 image carrying frame n in its red channel.
+func numbered(n int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Pix[0] = uint8(n)
+	return img
+}
+
+func TestRenderFramesOrder(t *testing.T) {
+	tests := []struct {
+		count, workers, ahead int
+	}{
+		{0, 4, 8},
+		{1, 4, 8},
+		{50, 1, 1},
+		{50, 4, 0}, // ahead below workers is raised to workers
+		{50, 4, 8},
+		{100, 16, 16},
+	}
+	for _, tt := range tests {
+		var inFlight, most atomic.Int32
+		render := func(n int) (*image.RGBA, error) {
+			if f := inFlight.Add(1); f > most.Load() {
+				most.Store(f)
+			}
+			// Early frames take longest, so they finish out of order.
+			time.Sleep(time.Duration(10-n%10) * 100 * time.Microsecond)
+			return numbered(n), nil
+		}
+		var got []int
+		deliver := func(n int, img *image.RGBA) error {
+			inFlight.Add(-1)
+			if int(img.Pix[0]) != n%256 {
+				t.Errorf("frame %d delivered with the image of frame %d", n, img.Pix[0])
+			}
+			got = append(got, n)
+			return nil
+		}
+
+		err := renderFrames(context.Background(), tt.count, tt.workers, tt.ahead, render, deliver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != tt.count {
+			t.Fatalf("%+v: %d frames delivered, want %d", tt, len(got), tt.count)
+		}
+		for i, n := range got {
+			if n != i {
+				t.Fatalf("%+v: frame %d delivered in place %d", tt, n, i)
+			}
+		}
+		if limit := int32(max(tt.ahead, tt.workers)); most.Load() > limit {
+			t.Errorf("%+v: %d frames in flight, want at most %d", tt, most.Load(), limit)
+		}
+	}
+}
+
+func TestRenderFramesStops(t *testing.T) {
+	errRender := errors.New("render failed")
+	errDeliver := errors.New("deliver failed")
+
+	tests := []struct {
+		name       string
+		failRender int // Frame whose render fails, -1 for none
+		failWrite  int // Frame whose delivery fails, -1 for none
+		cancelAt   int // Frame after whose delivery ctx is cancelled, -1 for none
+		want       error
+	}{
+		{"render error", 7, -1, -1, errRender},
+		{"deliver error", -1, 7, -1, errDeliver},
+		{"cancelled", -1, -1, 7, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var mu sync.Mutex
+			rendered := map[int]bool{}
+			render := func(n int) (*image.RGBA, error) {
+				mu.Lock()
+				rendered[n] = true
+				mu.Unlock()
+				if n == tt.failRender {
+					return nil, errRender
+				}
+				time.Sleep(time.Millisecond)
+				return numbered(n), nil
+			}
+			last := -1
+			deliver := func(n int, img *image.RGBA) error {
+				last = n
+				if n == tt.failWrite {
+					return errDeliver
+				}
+				if n == tt.cancelAt {
+					cancel()
+				}
+				return nil
+			}
+
+			result := make(chan error)
+			go func() {
+				result <- renderFrames(ctx, 1000, 4, 8, render, deliver)
+			}()
+			var err error
+			select {
+			case err = <-result:
+			case <-time.After(10 * time.Second):
+				t.Fatal("renderFrames did not return")
+			}
+
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error %v, want %v", err, tt.want)
+			}
+			if last > 7 {
+				t.Errorf("frame %d delivered after the run stopped at frame 7", last)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(rendered) > 7+1+8 {
+				t.Errorf("%d frames rendered, the run should stop within ahead frames of 7", len(rendered))
+			}
+		})
+	}
+}
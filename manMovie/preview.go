@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// preview is a dry run of the job. Every nth frame is rendered at
+// -preview-size into a contact sheet next to the output, the scale and
+// iterations of those frames are printed, and their render times are
+// scaled up to estimate how long the full movie will take.
+func (m *Movie) preview(ctx context.Context, tl Timeline) error {
+	pm := *m
+	pm.W = max(1, int(math.Round(float64(m.W)*m.PreviewSize)))
+	pm.H = max(1, int(math.Round(float64(m.H)*m.PreviewSize)))
+	ratio := float64(m.W*m.H) / float64(pm.W*pm.H)
+
+	start := time.Now()
+	render, _, err := pm.renderer(tl)
+	if err != nil {
+		return err
+	}
+	setup := time.Since(start)
+
+	fmt.Printf("preview: every %d of %d frames at %d x %d\n", m.Preview, m.Frames, pm.W, pm.H)
+	fmt.Println("frame     time        scale  iterations  rotation   render")
+	var frames []*image.RGBA
+	var spent time.Duration
+	for n := 0; n < m.Frames; n += m.Preview {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		t := time.Now()
+		img, err := render(n)
+		if err != nil {
+			return err
+		}
+		d := time.Since(t)
+		spent += d
+		frames = append(frames, img)
+
+		v := m.frameView(tl, n)
+		fmt.Printf("%5d %7.2fs %12.4g %11d %9.1f %8s\n", n, float64(n)/float64(m.FPS),
+			v.Scale, v.Iterations, v.Rotation, d.Round(time.Millisecond))
+	}
+
+	// Render time grows with the pixel count, and each sampled frame
+	// stands for the frames up to the next one.
+	estimate := time.Duration(float64(setup+spent*time.Duration(m.Preview)) * ratio)
+	fmt.Printf("preview: the full movie should take about %s\n", estimate.Round(time.Second))
+
+	fileName := strings.TrimSuffix(m.Output, filepath.Ext(m.Output)) + "-preview.png"
+	err = writeContactSheet(fileName, frames)
+	if err != nil {
+		return err
+	}
+	fmt.Println("preview: contact sheet", fileName)
+	return nil
+}
+
+// writeContactSheet lays the frames out in a grid, left to right and top
+// to bottom, as close to square as their count allows.
+func writeContactSheet(fileName string, frames []*image.RGBA) error {
+	if len(frames) == 0 {
+		return errors.New("writeContactSheet: no frames to lay out")
+	}
+	const gap = 4
+	fw, fh := frames[0].Bounds().Dx(), frames[0].Bounds().Dy()
+	cols := int(math.Ceil(math.Sqrt(float64(len(frames)))))
+	rows := (len(frames) + cols - 1) / cols
+
+	sheet := image.NewRGBA(image.Rect(0, 0, cols*(fw+gap)+gap, rows*(fh+gap)+gap))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(color.RGBA{32, 32, 32, 0xff}),
+		image.Point{}, draw.Src)
+	for i, img := range frames {
+		x, y := gap+(i%cols)*(fw+gap), gap+(i/cols)*(fh+gap)
+		draw.Draw(sheet, image.Rect(x, y, x+fw, y+fh), img, img.Bounds().Min, draw.Src)
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(file, sheet)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
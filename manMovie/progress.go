@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// progress keeps one status line up to date with the frames done, the
+// frame rate and the time left.
+type progress struct {
+	total int
+	start time.Time
+	shown time.Time
+}
+
+func newProgress(total int) *progress {
+	return &progress{total: total, start: time.Now()}
+}
+
+// Frame reports that done frames are finished. The line is redrawn at
+// most a few times a second, and always for the last frame.
+func (p *progress) Frame(done int) {
+	now := time.Now()
+	if done < p.total && now.Sub(p.shown) < 250*time.Millisecond {
+		return
+	}
+	p.shown = now
+
+	elapsed := now.Sub(p.start)
+	line := fmt.Sprintf("frame %d/%d %3d%%  elapsed %s", done, p.total,
+		100*done/max(p.total, 1), elapsed.Round(time.Second))
+	var perFrame time.Duration
+	if done > 0 {
+		perFrame = elapsed / time.Duration(done)
+	}
+	// Frames from the cache can all be done within one clock tick, which
+	// gives no rate to show.
+	if perFrame > 0 {
+		line += fmt.Sprintf("  %.2f fps  eta %s", float64(time.Second)/float64(perFrame),
+			(perFrame * time.Duration(p.total-done)).Round(time.Second))
+	}
+	// Pad over whatever was left of a longer line.
+	fmt.Printf("\r%-70s", line)
+}
+
+// Done ends the status line.
+func (p *progress) Done() {
+	fmt.Println()
+}
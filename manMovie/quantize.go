@@ -0,0 +1,90 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// quantize picks a palette of at most n colours for img by median cut.
+// Every step splits the box with the widest channel at its median, then
+// each box becomes the average of its pixels.
+func quantize(img *image.RGBA, n int) color.Palette {
+	// Large frames are sampled, the palette does not need every pixel.
+	b := img.Bounds()
+	step := 1
+	for (b.Dx()/step)*(b.Dy()/step) > 1<<16 {
+		step++
+	}
+
+	var pixels [][3]uint8
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			i := img.PixOffset(x, y)
+			pixels = append(pixels, [3]uint8{img.Pix[i], img.Pix[i+1], img.Pix[i+2]})
+		}
+	}
+
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < n {
+		// Split the box with the widest range.
+		best, bestCh, bestRange := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			ch, r := widestChannel(box)
+			if r > bestRange {
+				best, bestCh, bestRange = i, ch, r
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.Slice(box, func(i, j int) bool { return box[i][bestCh] < box[j][bestCh] })
+		mid := len(box) / 2
+		boxes[best] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	p := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		if len(box) == 0 {
+			continue
+		}
+		var r, g, b int
+		for _, px := range box {
+			r += int(px[0])
+			g += int(px[1])
+			b += int(px[2])
+		}
+		p = append(p, color.RGBA{uint8(r / len(box)), uint8(g / len(box)), uint8(b / len(box)), 0xff})
+	}
+	return p
+}
+
+// widestChannel returns the channel with the largest spread in box.
+func widestChannel(box [][3]uint8) (int, int) {
+	lo := [3]uint8{255, 255, 255}
+	var hi [3]uint8
+	for _, px := range box {
+		for ch := 0; ch < 3; ch++ {
+			if px[ch] < lo[ch] {
+				lo[ch] = px[ch]
+			}
+			if px[ch] > hi[ch] {
+				hi[ch] = px[ch]
+			}
+		}
+	}
+
+	best, bestRange := 0, -1
+	for ch := 0; ch < 3; ch++ {
+		if r := int(hi[ch]) - int(lo[ch]); r > bestRange {
+			best, bestRange = ch, r
+		}
+	}
+	return best, bestRange
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// striped is an image with one column per colour in cs.
+func striped(h int, cs ...color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(cs), h))
+	for y := 0; y < h; y++ {
+		for x, c := range cs {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestQuantize(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	green := color.RGBA{0, 0xff, 0, 0xff}
+	blue := color.RGBA{0, 0, 0xff, 0xff}
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+
+	// A smooth ramp over 512 by 512 pixels, which is sampled.
+	ramp := image.NewRGBA(image.Rect(0, 0, 512, 512))
+	for y := 0; y < 512; y++ {
+		for x := 0; x < 512; x++ {
+			ramp.SetRGBA(x, y, color.RGBA{uint8(x / 2), uint8(y / 2), 0x80, 0xff})
+		}
+	}
+
+	tests := []struct {
+		name  string
+		img   *image.RGBA
+		n     int
+		exact []color.RGBA // Colours the palette must hold, nil to skip
+		size  int          // The palette size
+	}{
+		{"one colour", striped(3, red), 256, []color.RGBA{red}, 1},
+		{"four colours", striped(3, red, green, blue, white), 256, []color.RGBA{red, green, blue, white}, 4},
+		{"four colours in four", striped(3, red, green, blue, white), 4, []color.RGBA{red, green, blue, white}, 4},
+		{"four colours in two", striped(3, red, green, blue, white), 2, nil, 2},
+		{"ramp", ramp, 256, nil, 256},
+		{"ramp in 16", ramp, 16, nil, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := quantize(tt.img, tt.n)
+			if len(p) != tt.size {
+				t.Fatalf("%d colours, want %d", len(p), tt.size)
+			}
+			for _, c := range tt.exact {
+				if p.Convert(c) != c {
+					t.Errorf("%v became %v", c, p.Convert(c))
+				}
+			}
+			for _, c := range p {
+				if _, _, _, a := c.RGBA(); a != 0xffff {
+					t.Errorf("%v is not opaque", c)
+				}
+			}
+		})
+	}
+}
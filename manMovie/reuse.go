@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"jsdey.com/fractal"
+)
+
+// frameReuse derives the frames of a zoom from oversized keyframes. A
+// keyframe is rendered factor times larger than the movie, and the frames
+// after it that lie inside it and are not more than factor times deeper
+// are resampled from it. Every derived frame is checked against a small
+// probe render and rendered in full when it strays too far.
+type frameReuse struct {
+	m        *Movie
+	tl       Timeline
+	factor   float64
+	maxErr   float64
+	segments []*reuseSegment // The segment of every frame
+	renders  atomic.Int32    // Frames that failed the check
+}
+
+// reuseSegment is a keyframe and the frames drawn from it.
+type reuseSegment struct {
+	view      fractal.View
+	w, h      int
+	remaining atomic.Int32 // Frames still to be drawn, the image is dropped at 0
+	once      sync.Once
+	img       *image.RGBA
+	err       error
+}
+
+// newFrameReuse splits the movie into keyframe segments.
+func (m *Movie) newFrameReuse(tl Timeline, factor, maxErr float64) (*frameReuse, error) {
+	if factor < 1 {
+		return nil, fmt.Errorf("newFrameReuse: factor %g must be at least 1", factor)
+	}
+	r := &frameReuse{m: m, tl: tl, factor: factor, maxErr: maxErr,
+		segments: make([]*reuseSegment, m.Frames)}
+
+	var cur *reuseSegment
+	for n := range r.segments {
+		v := m.frameView(tl, n)
+		if cur == nil || !r.covers(cur, v) {
+			cur = &reuseSegment{
+				view: v,
+				w:    int(math.Ceil(float64(m.W) * factor)),
+				h:    int(math.Ceil(float64(m.H) * factor)),
+			}
+		}
+		cur.remaining.Add(1)
+		r.segments[n] = cur
+	}
+	return r, nil
+}
+
+// covers reports whether frame v can be drawn from the segment's keyframe
+// without enlarging it.
+func (r *frameReuse) covers(s *reuseSegment, v fractal.View) bool {
+	if v.Offset != s.view.Offset || v.Scale > s.view.Scale || v.Scale*r.factor < s.view.Scale {
+		return false
+	}
+	for _, corner := range [4][2]int{{0, 0}, {r.m.W, 0}, {0, r.m.H}, {r.m.W, r.m.H}} {
+		c := v.Point(float64(corner[0]), float64(corner[1]), r.m.W, r.m.H)
+		px, py := s.view.Pixel(c, s.w, s.h)
+		if px < 0 || py < 0 || px > float64(s.w) || py > float64(s.h) {
+			return false
+		}
+	}
+	return true
+}
+
+// Keyframes is the number of full size renders the movie needs.
+func (r *frameReuse) Keyframes() int {
+	n := 0
+	for i, s := range r.segments {
+		if i == 0 || s != r.segments[i-1] {
+			n++
+		}
+	}
+	return n
+}
+
+// Render draws frame n.
+func (r *frameReuse) Render(n int) (*image.RGBA, error) {
+	s := r.segments[n]
+	defer r.Skip(n)
+
+	s.once.Do(func() {
+		s.img, s.err = s.view.Render(s.w, s.h)
+	})
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	v := r.m.frameView(r.tl, n)
+	img := s.resample(v, r.m.W, r.m.H, grid(2))
+	if v == s.view {
+		return img, nil
+	}
+
+	// Compare a small supersampled render of the frame with the same
+	// points taken from the keyframe. Averaging keeps the aliasing of a
+	// coarse grid out of the error.
+	pw, ph := max(r.m.W/16, 16), max(r.m.H/16, 9)
+	pv := v
+	pv.Samples = 4
+	probe, err := pv.Render(pw, ph)
+	if err != nil {
+		return nil, err
+	}
+	if meanError(probe, s.resample(v, pw, ph, grid(pv.Samples))) > r.maxErr {
+		r.renders.Add(1)
+		return v.Render(r.m.W, r.m.H)
+	}
+	return img, nil
+}
+
+// Skip counts frame n as drawn without rendering it, as when it comes
+// from the frame cache, and drops its keyframe once no frame needs it.
+func (r *frameReuse) Skip(n int) {
+	s := r.segments[n]
+	if s.remaining.Add(-1) == 0 {
+		s.img = nil
+	}
+}
+
+// grid returns the offsets of an n by n grid of samples within a pixel,
+// placed as View.Samples places them.
+func grid(n int) [][2]float64 {
+	offsets := make([][2]float64, 0, n*n)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			offsets = append(offsets, [2]float64{
+				(float64(x)+0.5)/float64(n) - 0.5,
+				(float64(y)+0.5)/float64(n) - 0.5,
+			})
+		}
+	}
+	return offsets
+}
+
+// resample draws a w by h image of v from the keyframe, averaging
+// bilinear samples at the offsets in every pixel.
+func (s *reuseSegment) resample(v fractal.View, w, h int, offsets [][2]float64) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	n := float64(len(offsets))
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			var sum [3]float64
+			for _, o := range offsets {
+				c := v.Point(float64(px)+o[0], float64(py)+o[1], w, h)
+				kx, ky := s.view.Pixel(c, s.w, s.h)
+				col := bilinear(s.img, kx, ky)
+				sum[0] += float64(col.R)
+				sum[1] += float64(col.G)
+				sum[2] += float64(col.B)
+			}
+			img.SetRGBA(px, py, color.RGBA{uint8(sum[0]/n + 0.5), uint8(sum[1]/n + 0.5),
+				uint8(sum[2]/n + 0.5), 0xff})
+		}
+	}
+	return img
+}
+
+// bilinear samples img at a fractional pixel position, clamped to its
+// edges.
+func bilinear(img *image.RGBA, x, y float64) color.RGBA {
+	b := img.Bounds()
+	x = math.Max(0, math.Min(x, float64(b.Dx()-1)))
+	y = math.Max(0, math.Min(y, float64(b.Dy()-1)))
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, b.Dx()-1), min(y0+1, b.Dy()-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	var out [3]float64
+	for _, s := range [4]struct {
+		x, y int
+		w    float64
+	}{{x0, y0, (1 - fx) * (1 - fy)}, {x1, y0, fx * (1 - fy)}, {x0, y1, (1 - fx) * fy}, {x1, y1, fx * fy}} {
+		p := img.PixOffset(b.Min.X+s.x, b.Min.Y+s.y)
+		for ch := 0; ch < 3; ch++ {
+			out[ch] += s.w * float64(img.Pix[p+ch])
+		}
+	}
+	return color.RGBA{uint8(out[0] + 0.5), uint8(out[1] + 0.5), uint8(out[2] + 0.5), 0xff}
+}
+
+// meanError is the mean absolute difference per channel of two images of
+// the same size, on the 0 to 255 scale.
+func meanError(a, b *image.RGBA) float64 {
+	var sum, n float64
+	for i := 0; i < len(a.Pix); i += 4 {
+		for ch := 0; ch < 3; ch++ {
+			sum += math.Abs(float64(a.Pix[i+ch]) - float64(b.Pix[i+ch]))
+			n++
+		}
+	}
+	return sum / n
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"jsdey.com/fractal"
+)
+
+// schedules are the ways of picking the iterations of each frame. fixed
+// keeps -i and the keyframe iterations, the others set every frame from
+// its scale.
+var schedules = []string{"fixed", "auto", "curve", "adaptive"}
+
+// curvePoint is one scale:iterations pair of -curve.
+type curvePoint struct {
+	scale      float64
+	iterations float64
+}
+
+// parseCurve reads comma separated scale:iterations pairs such as
+// "1:100,1e-4:1500,1e-8:6000", sorted from the shallowest scale down.
+func parseCurve(s string) ([]curvePoint, error) {
+	var curve []curvePoint
+	for _, pair := range strings.Split(s, ",") {
+		scale, iterations, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("parseCurve: %q is not scale:iterations", pair)
+		}
+		p := curvePoint{}
+		var err error
+		p.scale, err = strconv.ParseFloat(scale, 64)
+		if err != nil || p.scale <= 0 {
+			return nil, fmt.Errorf("parseCurve: bad scale %q", scale)
+		}
+		p.iterations, err = strconv.ParseFloat(iterations, 64)
+		if err != nil || p.iterations < 1 {
+			return nil, fmt.Errorf("parseCurve: bad iterations %q", iterations)
+		}
+		curve = append(curve, p)
+	}
+	sort.Slice(curve, func(i, j int) bool { return curve[i].scale > curve[j].scale })
+	return curve, nil
+}
+
+// curveAt interpolates the curve linearly in log scale. Scales beyond either
+// end hold the end's iterations.
+func curveAt(curve []curvePoint, scale float64) int {
+	if scale >= curve[0].scale {
+		return int(curve[0].iterations)
+	}
+	for i := 1; i < len(curve); i++ {
+		a, b := curve[i-1], curve[i]
+		if scale >= b.scale {
+			f := math.Log(scale/a.scale) / math.Log(b.scale/a.scale)
+			return int(math.Round(a.iterations + f*(b.iterations-a.iterations)))
+		}
+	}
+	return int(curve[len(curve)-1].iterations)
+}
+
+// scheduleIterations works out the iterations of every frame for the
+// auto, curve and adaptive schedules. The fixed schedule leaves m.iters
+// empty so that frames keep the timeline's iterations.
+func (m *Movie) scheduleIterations(tl Timeline) error {
+	scale := func(n int) float64 { return m.frameView(tl, n).Scale }
+
+	var iterations func(n int) (int, error)
+	switch m.Schedule {
+	case "fixed":
+		return nil
+	case "auto":
+		iterations = func(n int) (int, error) { return fractal.AutoIterations(scale(n)), nil }
+	case "curve":
+		curve, err := parseCurve(m.Curve)
+		if err != nil {
+			return err
+		}
+		iterations = func(n int) (int, error) { return curveAt(curve, scale(n)), nil }
+	case "adaptive":
+		return m.adaptiveIterations(tl)
+	default:
+		return fmt.Errorf("scheduleIterations: unknown schedule %q", m.Schedule)
+	}
+
+	m.iters = make([]int, m.Frames)
+	for n := range m.iters {
+		i, err := iterations(n)
+		if err != nil {
+			return err
+		}
+		m.iters[n] = min(i, m.MaxIter)
+	}
+	return nil
+}
+
+// adaptiveIterations probes a small render of the movie twice a second.
+// The iterations of a probe grow until fewer than one pixel in two
+// thousand still escapes when they are raised by half again, which is
+// where the remaining interior is real rather than a too low limit.
+// Frames between probes are interpolated.
+func (m *Movie) adaptiveIterations(tl Timeline) error {
+	const minIter, grow, settled = 64, 1.5, 0.0005
+
+	step := max(1, m.FPS/2)
+	pw, ph := max(m.W/16, 32), max(m.H/16, 18)
+	fmt.Printf("scheduleIterations: probing every %d frames at %d x %d\n", step, pw, ph)
+
+	interior := func(v fractal.View) (int, error) {
+		field, err := v.Compute(pw, ph)
+		if err != nil {
+			return 0, err
+		}
+		count := 0
+		for _, mu := range field.Mu {
+			if mu < 0 {
+				count++
+			}
+		}
+		return count, nil
+	}
+
+	probe := func(n int) (int, error) {
+		v := m.frameView(tl, n)
+		v.Samples = 1
+		v.Iterations = min(minIter, m.MaxIter)
+		inside, err := interior(v)
+		if err != nil {
+			return 0, err
+		}
+		for v.Iterations < m.MaxIter {
+			next := v
+			next.Iterations = min(int(float64(v.Iterations)*grow), m.MaxIter)
+			nextInside, err := interior(next)
+			if err != nil {
+				return 0, err
+			}
+			if float64(inside-nextInside) < settled*float64(pw*ph) {
+				break
+			}
+			v, inside = next, nextInside
+		}
+		return v.Iterations, nil
+	}
+
+	m.iters = make([]int, m.Frames)
+	last, lastIter := -1, 0
+	for n := 0; n < m.Frames; n += step {
+		if n+step >= m.Frames {
+			n = m.Frames - 1
+		}
+		i, err := probe(n)
+		if err != nil {
+			return err
+		}
+		m.iters[n] = i
+		for k := last + 1; k < n && last >= 0; k++ {
+			f := float64(k-last) / float64(n-last)
+			m.iters[k] = int(math.Round(float64(lastIter) + f*float64(i-lastIter)))
+		}
+		last, lastIter = n, i
+	}
+	if last < 0 {
+		return errors.New("adaptiveIterations: the movie has no frames")
+	}
+	return nil
+}
+
+// frameIterations is the scheduled iteration count at time t, or 0 when
+// the timeline's iterations are used.
+func (m *Movie) frameIterations(t float64) int {
+	if len(m.iters) == 0 {
+		return 0
+	}
+	n := int(math.Round(t * float64(m.FPS)))
+	return m.iters[max(0, min(n, len(m.iters)-1))]
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCurve(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []curvePoint
+		err  string // Part of the error, empty for none
+	}{
+		{"1:100", []curvePoint{{1, 100}}, ""},
+		{"1:100,1e-4:1500,1e-8:6000", []curvePoint{{1, 100}, {1e-4, 1500}, {1e-8, 6000}}, ""},
+		{"1e-8:6000, 1:100 ,1e-4:1500", []curvePoint{{1, 100}, {1e-4, 1500}, {1e-8, 6000}}, ""},
+		{"", nil, `"" is not scale:iterations`},
+		{"1:100,", nil, `"" is not scale:iterations`},
+		{"1=100", nil, `"1=100" is not scale:iterations`},
+		{"0:100", nil, `bad scale "0"`},
+		{"-1:100", nil, `bad scale "-1"`},
+		{"deep:100", nil, `bad scale "deep"`},
+		{"1:0.5", nil, `bad iterations "0.5"`},
+		{"1:many", nil, `bad iterations "many"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			curve, err := parseCurve(tt.s)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one with %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(curve, tt.want) {
+				t.Errorf("parseCurve(%q) = %v, want %v", tt.s, curve, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurveAt(t *testing.T) {
+	curve := []curvePoint{{1, 100}, {1e-4, 1500}, {1e-8, 6000}}
+	tests := []struct {
+		scale float64
+		want  int
+	}{
+		{10, 100},    // Shallower than the curve
+		{1, 100},     // At the first point
+		{1e-2, 800},  // Halfway in log scale
+		{1e-1, 450},  // A quarter of the way
+		{1e-4, 1500}, // At a point
+		{1e-6, 3750},
+		{1e-8, 6000},  // At the last point
+		{1e-12, 6000}, // Deeper than the curve
+	}
+	for _, tt := range tests {
+		if got := curveAt(curve, tt.scale); got != tt.want {
+			t.Errorf("curveAt(%g) = %d, want %d", tt.scale, got, tt.want)
+		}
+	}
+
+	single := []curvePoint{{1e-3, 500}}
+	for _, scale := range []float64{1, 1e-3, 1e-9} {
+		if got := curveAt(single, scale); got != 500 {
+			t.Errorf("one point curve at %g gives %d, want 500", scale, got)
+		}
+	}
+}
+
+func TestScheduleCeiling(t *testing.T) {
+	tl := Timeline{
+		{Time: 0, X: -0.75, Y: 0.1, Scale: 1, Iterations: 100, Easing: "linear"},
+		{Time: 2, X: -0.7436, Y: 0.1318, Scale: 1e-6, Iterations: 100, Easing: "linear"},
+	}
+	for _, schedule := range []string{"auto", "curve", "adaptive"} {
+		t.Run(schedule, func(t *testing.T) {
+			m := &Movie{Job: Job{FPS: 4, Duration: 2, W: 64, H: 36, Schedule: schedule,
+				Curve: "1:100,1e-6:5000", MaxIter: 50}}
+			m.Frames = m.Job.Frames()
+			err := m.scheduleIterations(tl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m.iters) != m.Frames {
+				t.Fatalf("%d frames scheduled, want %d", len(m.iters), m.Frames)
+			}
+			for n, i := range m.iters {
+				if i < 1 || i > m.MaxIter {
+					t.Errorf("frame %d gets %d iterations, want 1 to %d", n, i, m.MaxIter)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// tileCache is a fixed size least recently used cache of encoded tiles.
+type tileCache struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List // Front is the most recently used tile
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key string
+	png []byte
+}
+
+func newTileCache(max int) *tileCache {
+	return &tileCache{
+		max:     max,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *tileCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*cacheEntry).png, true
+}
+
+func (c *tileCache) Add(key string, png []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*cacheEntry).png = png
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key, png})
+
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
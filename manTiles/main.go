@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"image/png"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"jsdey.com/fractal"
+)
+
+//go:embed static
+var static embed.FS
+
+const (
+	TileSize = 256
+	MaxZoom  = 46 // Beyond this float64 runs out of precision
+	MaxIter  = 100000
+
+	// The square of the plane shown by the single tile at zoom 0.
+	WorldLeft = -2.75
+	WorldTop  = -2.0
+	WorldSize = 4.0
+)
+
+type Server struct {
+	cache *tileCache
+	index *template.Template
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	cacheSize := flag.Int("cache", 4096, "number of tiles to keep in memory, 0 for none")
+	flag.Parse()
+	if *cacheSize < 0 {
+		log.Fatalf("manTiles: -cache %d must not be negative", *cacheSize)
+	}
+
+	index, err := template.ParseFS(static, "static/index.html")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := &Server{cache: newTileCache(*cacheSize), index: index}
+
+	http.HandleFunc("/", s.serveIndex)
+	http.HandleFunc("/tiles/", s.serveTile)
+
+	fmt.Println("manTiles: serving on http://" + *addr + "/")
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := struct {
+		TileSize           int
+		MaxZoom            int
+		Formulas, Palettes []string
+		Formula, Palette   string
+	}{TileSize, MaxZoom, fractal.FormulaNames(), fractal.PaletteNames(),
+		fractal.DefaultFormula, fractal.DefaultPalette}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := s.index.Execute(w, data)
+	if err != nil {
+		log.Println("serveIndex:", err)
+	}
+}
+
+// serveTile answers /tiles/{z}/{x}/{y}.png with optional formula, palette
+// and iter query parameters.
+func (s *Server) serveTile(w http.ResponseWriter, r *http.Request) {
+	v, err := parseTile(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key := fmt.Sprintf("%s|%s|%d|%s", v.Formula, v.Palette, v.Iterations, r.URL.Path)
+	b, ok := s.cache.Get(key)
+	if !ok {
+		img, err := v.Render(TileSize, TileSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var buf bytes.Buffer
+		err = png.Encode(&buf, img)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		b = buf.Bytes()
+		s.cache.Add(key, b)
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(b)
+}
+
+// parseTile converts a tile request into the view that covers the tile.
+func parseTile(r *http.Request) (fractal.View, error) {
+	var v fractal.View
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/tiles/"), "/")
+	if len(parts) != 3 || !strings.HasSuffix(parts[2], ".png") {
+		return v, errors.New("parseTile: expected /tiles/{z}/{x}/{y}.png")
+	}
+	parts[2] = strings.TrimSuffix(parts[2], ".png")
+
+	z, err := strconv.Atoi(parts[0])
+	if err != nil || z < 0 || z > MaxZoom {
+		return v, fmt.Errorf("parseTile: zoom must be between 0 and %d", MaxZoom)
+	}
+	n := int64(1) << z
+	x, errX := strconv.ParseInt(parts[1], 10, 64)
+	y, errY := strconv.ParseInt(parts[2], 10, 64)
+	if errX != nil || errY != nil || x < 0 || y < 0 || x >= n || y >= n {
+		return v, fmt.Errorf("parseTile: tile %s/%s is outside zoom %d", parts[1], parts[2], z)
+	}
+
+	size := WorldSize / math.Exp2(float64(z))
+	v.Scale = size / 3.5
+	v.X = WorldLeft + (float64(x)+0.5)*size
+	v.Y = -(WorldTop + (float64(y)+0.5)*size)
+
+	q := r.URL.Query()
+	v.Formula = q.Get("formula")
+	v.Palette = q.Get("palette")
+	if _, err := fractal.LookupFormula(v.Formula); err != nil {
+		return v, err
+	}
+	if _, err := fractal.LookupPalette(v.Palette); err != nil {
+		return v, err
+	}
+
+	v.Iterations = fractal.AutoIterations(v.Scale)
+	if iter := q.Get("iter"); iter != "" {
+		v.Iterations, err = strconv.Atoi(iter)
+		if err != nil || v.Iterations < 1 || v.Iterations > MaxIter {
+			return v, fmt.Errorf("parseTile: bad iteration count %q", iter)
+		}
+	}
+	return v, nil
+}